@@ -0,0 +1,459 @@
+package httphandler
+
+import (
+	"container/list"
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache is a Presenter which caches the responses of another
+// Presenter in memory. Only responses to GET and HEAD requests with
+// buffered bodies and no cookies are cached. How long a response
+// stays fresh comes from its Cache-Control (s-maxage or max-age) or
+// Expires header or, failing that, DefaultTTL. Responses marked
+// no-store, no-cache or private are not cached and neither are
+// responses which "Vary: *". As in RFC 9111 section 3.5, responses to
+// requests with an Authorization header are only cached if they are
+// marked public, s-maxage or must-revalidate. DefaultTTL does not
+// apply to requests with a Cookie header since their responses are
+// likely to be personalized.
+// A Cache must not be copied after first use.
+//
+// A Cache can also wrap an ErrPresenter (by setting ErrPresenter
+// instead of Presenter) in which case it is used as an ErrPresenter
+// itself, typically inside an ErrHandler. Responses returned along
+// with an error are not cached.
+//
+// Stale responses can still be used as described in RFC 5861. Within
+// the stale-while-revalidate window a stale response is returned
+// straight away while a fresh one is fetched in the background. Within
+// the stale-if-error window a stale response is returned instead of
+// the zero Response or a 500, 502, 503 or 504 response but any error
+// is still returned so that it can be logged. Other responses are
+// returned as they are even if they come with an error (like a 404
+// from an ErrMapper). The windows come from
+// the Cache-Control header of the cached response or, failing that,
+// StaleWhileRevalidate and StaleIfError.
+type Cache struct {
+	Presenter    Presenter
+	ErrPresenter ErrPresenter
+	// Key returns the key a request is cached under. It defaults
+	// to the request's method, host and URL. Request headers
+	// listed in the Vary header of a cached response are always
+	// added to it.
+	Key                  func(*http.Request) string
+	DefaultTTL           time.Duration
+	StaleWhileRevalidate time.Duration
+	StaleIfError         time.Duration
+	// HandleErr is called with errors from background
+	// revalidation since there is no one else to report them to.
+	HandleErr func(*http.Request, error)
+	// MaxEntries and MaxBytes bound the size of the cache. Least
+	// recently used responses are evicted first. Zero means
+	// unbounded.
+	MaxEntries int
+	MaxBytes   int
+	// Now returns the current time. It defaults to time.Now.
+	Now func() time.Time
+
+	mu      sync.Mutex
+	lru     *list.List
+	entries map[string]*list.Element
+	// vary holds the Vary header of the last response cached under
+	// a given key.
+	vary  map[string][]string
+	stats CacheStats
+}
+
+// CacheStats describes how a Cache has been doing. Stale counts the
+// stale responses which were returned.
+type CacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Stale     uint64
+	Evictions uint64
+	Entries   int
+	Bytes     int
+}
+
+// cacheEntry is a cached response. Everything but revalidating is
+// immutable once the entry is in the cache.
+type cacheEntry struct {
+	key                  string
+	resp                 Response
+	size                 int
+	stored               time.Time
+	expires              time.Time
+	staleWhileRevalidate time.Duration
+	staleIfError         time.Duration
+	revalidating         bool
+}
+
+// response returns a copy of the cached response with its age.
+func (e *cacheEntry) response(now time.Time) Response {
+	resp := copyResponse(e.resp)
+	if resp.Header == nil {
+		resp.Header = http.Header{}
+	}
+	resp.Header.Set("Age", strconv.Itoa(int(now.Sub(e.stored)/time.Second)))
+	return resp
+}
+
+// retainUntil returns when the entry becomes useless.
+func (e *cacheEntry) retainUntil() time.Time {
+	stale := e.staleWhileRevalidate
+	if e.staleIfError > stale {
+		stale = e.staleIfError
+	}
+	return e.expires.Add(stale)
+}
+
+// PresentHTTP returns a cached response for the request if there is
+// a usable one and otherwise gets one from Presenter and caches it.
+func (c *Cache) PresentHTTP(r *http.Request) Response {
+	resp, _ := c.ErrPresentHTTP(r)
+	return resp
+}
+
+// ErrPresentHTTP is like PresentHTTP but also returns the error from
+// ErrPresenter.
+func (c *Cache) ErrPresentHTTP(r *http.Request) (Response, error) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return c.fetch(r)
+	}
+	key := c.key(r)
+	entry, now := c.lookup(r, key)
+	if entry != nil && now.Before(entry.expires) {
+		c.count(&c.stats.Hits)
+		return entry.response(now), nil
+	}
+	if entry != nil && now.Before(entry.expires.Add(entry.staleWhileRevalidate)) {
+		c.count(&c.stats.Hits, &c.stats.Stale)
+		c.revalidate(r, key, entry)
+		return entry.response(now), nil
+	}
+	c.count(&c.stats.Misses)
+	resp, err := c.fetch(r)
+	if entry != nil && now.Before(entry.expires.Add(entry.staleIfError)) && failed(resp) {
+		c.count(&c.stats.Stale)
+		closeStream(resp)
+		return entry.response(now), err
+	}
+	if err == nil {
+		c.store(r, key, resp)
+	}
+	return resp, err
+}
+
+// Stats returns the statistics of the cache.
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// count increments statistics.
+func (c *Cache) count(stats ...*uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, stat := range stats {
+		*stat++
+	}
+}
+
+// fetch gets a response from whichever of Presenter or ErrPresenter
+// is set.
+func (c *Cache) fetch(r *http.Request) (Response, error) {
+	if c.ErrPresenter != nil {
+		return c.ErrPresenter.ErrPresentHTTP(r)
+	}
+	return c.Presenter.PresentHTTP(r), nil
+}
+
+// failed reports whether a response is one that stale-if-error
+// applies to. The zero Response counts since it means something went
+// wrong.
+func failed(resp Response) bool {
+	switch resp.StatusCode {
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return resp.isZero()
+}
+
+// revalidate fetches a fresh response for a stale entry in the
+// background unless that is already happening.
+func (c *Cache) revalidate(r *http.Request, key string, entry *cacheEntry) {
+	c.mu.Lock()
+	if entry.revalidating {
+		c.mu.Unlock()
+		return
+	}
+	entry.revalidating = true
+	c.mu.Unlock()
+	// The request the stale response is going to will be done long
+	// before the revalidation is.
+	r = r.Clone(context.WithoutCancel(r.Context()))
+	go func() {
+		defer func() {
+			c.mu.Lock()
+			entry.revalidating = false
+			c.mu.Unlock()
+		}()
+		resp, err := c.fetch(r)
+		if err != nil {
+			if c.HandleErr != nil {
+				c.HandleErr(r, err)
+			}
+			return
+		}
+		c.store(r, key, resp)
+	}()
+}
+
+// key returns the key for a request without the Vary part.
+func (c *Cache) key(r *http.Request) string {
+	if c.Key != nil {
+		return c.Key(r)
+	}
+	return requestKey(r)
+}
+
+// requestKey returns the request's method, host and URL. The host is
+// needed since the URL of a server request usually only has a path.
+func requestKey(r *http.Request) string {
+	return r.Method + " " + r.Host + " " + r.URL.String()
+}
+
+// now returns the current time.
+func (c *Cache) now() time.Time {
+	if c.Now != nil {
+		return c.Now()
+	}
+	return time.Now()
+}
+
+// lookup returns the cached entry for a request (if there is one which
+// is still of some use) along with the current time.
+func (c *Cache) lookup(r *http.Request, key string) (*cacheEntry, time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	elem, ok := c.entries[varyKey(key, c.vary[key], r)]
+	if !ok {
+		return nil, now
+	}
+	entry := elem.Value.(*cacheEntry)
+	if !now.Before(entry.retainUntil()) {
+		c.remove(elem)
+		return nil, now
+	}
+	c.lru.MoveToFront(elem)
+	return entry, now
+}
+
+// store caches a copy of resp if it is allowed to be cached.
+func (c *Cache) store(r *http.Request, key string, resp Response) {
+	now := c.now()
+	ttl, ok := c.ttl(r, resp, now)
+	if !ok {
+		return
+	}
+	vary := varyFields(resp.Header)
+	for _, field := range vary {
+		if field == "*" {
+			return
+		}
+	}
+	directives := cacheControl(resp.Header)
+	entry := &cacheEntry{
+		resp:                 copyResponse(resp),
+		size:                 responseSize(resp),
+		stored:               now,
+		expires:              now.Add(ttl),
+		staleWhileRevalidate: directiveSeconds(directives, "stale-while-revalidate", c.StaleWhileRevalidate),
+		staleIfError:         directiveSeconds(directives, "stale-if-error", c.StaleIfError),
+	}
+	if c.MaxBytes > 0 && entry.size > c.MaxBytes {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.lru = list.New()
+		c.entries = map[string]*list.Element{}
+		c.vary = map[string][]string{}
+	}
+	c.vary[key] = vary
+	entry.key = varyKey(key, vary, r)
+	if elem, ok := c.entries[entry.key]; ok {
+		c.remove(elem)
+	}
+	c.entries[entry.key] = c.lru.PushFront(entry)
+	c.stats.Entries++
+	c.stats.Bytes += entry.size
+	for (c.MaxEntries > 0 && c.stats.Entries > c.MaxEntries) || (c.MaxBytes > 0 && c.stats.Bytes > c.MaxBytes) {
+		c.remove(c.lru.Back())
+		c.stats.Evictions++
+	}
+}
+
+// directiveSeconds returns the duration given by a Cache-Control
+// directive or def if there is no such directive.
+func directiveSeconds(directives map[string]string, name string, def time.Duration) time.Duration {
+	value, ok := directives[name]
+	if !ok {
+		return def
+	}
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// remove removes an entry. The caller must hold c.mu.
+func (c *Cache) remove(elem *list.Element) {
+	entry := c.lru.Remove(elem).(*cacheEntry)
+	delete(c.entries, entry.key)
+	c.stats.Entries--
+	c.stats.Bytes -= entry.size
+}
+
+// ttl returns how long resp, the response to r, stays fresh and
+// whether it may be cached at all.
+func (c *Cache) ttl(r *http.Request, resp Response, now time.Time) (time.Duration, bool) {
+	// The zero value means something went wrong and that some
+	// other Presenter (like DefaultResp) should decide on the
+	// response.
+	if resp.isZero() || resp.Stream != nil || !cacheableStatus(resp.StatusCode) {
+		return 0, false
+	}
+	// Cookies are meant for one client and not everyone else who
+	// would get the cached response.
+	if len(resp.Cookies) > 0 || resp.Header.Get("Set-Cookie") != "" {
+		return 0, false
+	}
+	directives := cacheControl(resp.Header)
+	for _, directive := range []string{"no-store", "no-cache", "private"} {
+		if _, ok := directives[directive]; ok {
+			return 0, false
+		}
+	}
+	// A response to an authenticated request is probably meant
+	// for that user alone unless it says otherwise.
+	if r.Header.Get("Authorization") != "" {
+		_, public := directives["public"]
+		_, sMaxAge := directives["s-maxage"]
+		_, mustRevalidate := directives["must-revalidate"]
+		if !public && !sMaxAge && !mustRevalidate {
+			return 0, false
+		}
+	}
+	for _, directive := range []string{"s-maxage", "max-age"} {
+		if value, ok := directives[directive]; ok {
+			seconds, err := strconv.Atoi(value)
+			if err != nil || seconds <= 0 {
+				return 0, false
+			}
+			return time.Duration(seconds) * time.Second, true
+		}
+	}
+	if expiresHeader := resp.Header.Get("Expires"); expiresHeader != "" {
+		expires, err := http.ParseTime(expiresHeader)
+		if err != nil || !expires.After(now) {
+			return 0, false
+		}
+		return expires.Sub(now), true
+	}
+	if r.Header.Get("Cookie") != "" {
+		return 0, false
+	}
+	return c.DefaultTTL, c.DefaultTTL > 0
+}
+
+// cacheableStatus reports whether responses with a status code may be
+// cached (these are the "heuristically cacheable" ones in RFC 9110).
+func cacheableStatus(statusCode int) bool {
+	switch statusCode {
+	case 0, 200, 203, 204, 300, 301, 308, 404, 405, 410, 414, 501:
+		return true
+	}
+	return false
+}
+
+// cacheControl parses the Cache-Control header into a mapping from
+// directive to (possibly empty) argument.
+func cacheControl(h http.Header) map[string]string {
+	directives := map[string]string{}
+	for _, value := range h.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			name, arg, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			if name == "" {
+				continue
+			}
+			directives[strings.ToLower(name)] = strings.Trim(arg, `"`)
+		}
+	}
+	return directives
+}
+
+// varyFields returns the canonicalized fields in the Vary header.
+func varyFields(h http.Header) []string {
+	fields := []string{}
+	for _, value := range h.Values("Vary") {
+		for _, field := range strings.Split(value, ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				fields = append(fields, http.CanonicalHeaderKey(field))
+			}
+		}
+	}
+	return fields
+}
+
+// varyKey adds the values of the varying request headers to key.
+func varyKey(key string, vary []string, r *http.Request) string {
+	for _, field := range vary {
+		key += "\x00" + field + ":" + strings.Join(r.Header.Values(field), ",")
+	}
+	return key
+}
+
+// copyResponse returns a copy of resp which shares no memory with it
+// (apart from Stream).
+func copyResponse(resp Response) Response {
+	if resp.Header != nil {
+		resp.Header = cloneHeader(resp.Header)
+	}
+	if resp.Trailer != nil {
+		resp.Trailer = cloneHeader(resp.Trailer)
+	}
+	if resp.Cookies != nil {
+		cookies := make([]*http.Cookie, len(resp.Cookies))
+		for i, cookie := range resp.Cookies {
+			c := *cookie
+			cookies[i] = &c
+		}
+		resp.Cookies = cookies
+	}
+	if resp.Body != nil {
+		resp.Body = append([]byte{}, resp.Body...)
+	}
+	return resp
+}
+
+// responseSize approximates the memory used by a response.
+func responseSize(resp Response) int {
+	size := len(resp.Body)
+	for key, values := range resp.Header {
+		size += len(key)
+		for _, value := range values {
+			size += len(value)
+		}
+	}
+	return size
+}
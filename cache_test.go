@@ -0,0 +1,329 @@
+package httphandler_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lag13/httphandler"
+)
+
+// fakeClock is a clock which only moves when told to.
+type fakeClock struct {
+	now time.Time
+}
+
+func (f *fakeClock) Now() time.Time {
+	return f.now
+}
+
+// countingPresenter returns the given response with a body saying how
+// many times it has been called.
+type countingPresenter struct {
+	resp  httphandler.Response
+	calls int
+}
+
+func (c *countingPresenter) PresentHTTP(r *http.Request) httphandler.Response {
+	c.calls++
+	resp := c.resp
+	resp.Body = []byte(fmt.Sprintf("call %d", c.calls))
+	return resp
+}
+
+// TestCache tests that Cache serves cached responses while they are
+// fresh and only caches what it is allowed to.
+func TestCache(t *testing.T) {
+	type step struct {
+		advance   time.Duration
+		method    string
+		host      string
+		path      string
+		reqHeader http.Header
+		wantBody  string
+	}
+	tests := []struct {
+		name       string
+		resp       httphandler.Response
+		defaultTTL time.Duration
+		steps      []step
+	}{
+		{
+			name:       "cached for the default TTL",
+			resp:       httphandler.Response{StatusCode: 200},
+			defaultTTL: time.Minute,
+			steps: []step{
+				{method: http.MethodGet, path: "/a", wantBody: "call 1"},
+				{advance: 59 * time.Second, method: http.MethodGet, path: "/a", wantBody: "call 1"},
+				{method: http.MethodGet, path: "/b", wantBody: "call 2"},
+				{advance: time.Second, method: http.MethodGet, path: "/a", wantBody: "call 3"},
+			},
+		},
+		{
+			name:       "hosts do not share entries",
+			resp:       httphandler.Response{StatusCode: 200},
+			defaultTTL: time.Minute,
+			steps: []step{
+				{method: http.MethodGet, host: "a.example", path: "/", wantBody: "call 1"},
+				{method: http.MethodGet, host: "b.example", path: "/", wantBody: "call 2"},
+				{method: http.MethodGet, host: "a.example", path: "/", wantBody: "call 1"},
+			},
+		},
+		{
+			name:       "max-age beats the default TTL",
+			resp:       httphandler.Response{StatusCode: 200, Header: http.Header{"Cache-Control": {"public, max-age=10"}}},
+			defaultTTL: time.Minute,
+			steps: []step{
+				{method: http.MethodGet, path: "/a", wantBody: "call 1"},
+				{advance: 10 * time.Second, method: http.MethodGet, path: "/a", wantBody: "call 2"},
+			},
+		},
+		{
+			name: "Expires",
+			resp: httphandler.Response{StatusCode: 200, Header: http.Header{"Expires": {"Sat, 01 Jan 2000 00:00:30 GMT"}}},
+			steps: []step{
+				{method: http.MethodGet, path: "/a", wantBody: "call 1"},
+				{advance: 29 * time.Second, method: http.MethodGet, path: "/a", wantBody: "call 1"},
+				{advance: time.Second, method: http.MethodGet, path: "/a", wantBody: "call 2"},
+			},
+		},
+		{
+			name:       "no-store",
+			resp:       httphandler.Response{StatusCode: 200, Header: http.Header{"Cache-Control": {"no-store"}}},
+			defaultTTL: time.Minute,
+			steps: []step{
+				{method: http.MethodGet, path: "/a", wantBody: "call 1"},
+				{method: http.MethodGet, path: "/a", wantBody: "call 2"},
+			},
+		},
+		{
+			name:       "responses setting cookies are not cached",
+			resp:       httphandler.Response{StatusCode: 200, Cookies: []*http.Cookie{{Name: "session", Value: "1"}}},
+			defaultTTL: time.Minute,
+			steps: []step{
+				{method: http.MethodGet, path: "/a", wantBody: "call 1"},
+				{method: http.MethodGet, path: "/a", wantBody: "call 2"},
+			},
+		},
+		{
+			name:       "responses to authorized requests are not cached",
+			resp:       httphandler.Response{StatusCode: 200},
+			defaultTTL: time.Minute,
+			steps: []step{
+				{method: http.MethodGet, path: "/me", reqHeader: http.Header{"Authorization": {"alice"}}, wantBody: "call 1"},
+				{method: http.MethodGet, path: "/me", reqHeader: http.Header{"Authorization": {"bob"}}, wantBody: "call 2"},
+			},
+		},
+		{
+			name:       "public responses to authorized requests are cached",
+			resp:       httphandler.Response{StatusCode: 200, Header: http.Header{"Cache-Control": {"public, max-age=60"}}},
+			defaultTTL: time.Minute,
+			steps: []step{
+				{method: http.MethodGet, path: "/logo", reqHeader: http.Header{"Authorization": {"alice"}}, wantBody: "call 1"},
+				{method: http.MethodGet, path: "/logo", reqHeader: http.Header{"Authorization": {"bob"}}, wantBody: "call 1"},
+			},
+		},
+		{
+			name:       "the default TTL does not apply to requests with cookies",
+			resp:       httphandler.Response{StatusCode: 200},
+			defaultTTL: time.Minute,
+			steps: []step{
+				{method: http.MethodGet, path: "/me", reqHeader: http.Header{"Cookie": {"session=alice"}}, wantBody: "call 1"},
+				{method: http.MethodGet, path: "/me", reqHeader: http.Header{"Cookie": {"session=bob"}}, wantBody: "call 2"},
+			},
+		},
+		{
+			name:       "unsafe methods are not cached",
+			resp:       httphandler.Response{StatusCode: 200},
+			defaultTTL: time.Minute,
+			steps: []step{
+				{method: http.MethodPost, path: "/a", wantBody: "call 1"},
+				{method: http.MethodPost, path: "/a", wantBody: "call 2"},
+			},
+		},
+		{
+			name:       "error responses are not cached",
+			resp:       httphandler.Response{StatusCode: 500},
+			defaultTTL: time.Minute,
+			steps: []step{
+				{method: http.MethodGet, path: "/a", wantBody: "call 1"},
+				{method: http.MethodGet, path: "/a", wantBody: "call 2"},
+			},
+		},
+		{
+			name:       "vary",
+			resp:       httphandler.Response{StatusCode: 200, Header: http.Header{"Vary": {"accept"}}},
+			defaultTTL: time.Minute,
+			steps: []step{
+				{method: http.MethodGet, path: "/a", reqHeader: http.Header{"Accept": {"text/plain"}}, wantBody: "call 1"},
+				{method: http.MethodGet, path: "/a", reqHeader: http.Header{"Accept": {"application/json"}}, wantBody: "call 2"},
+				{method: http.MethodGet, path: "/a", reqHeader: http.Header{"Accept": {"text/plain"}}, wantBody: "call 1"},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clock := &fakeClock{now: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)}
+			sut := &httphandler.Cache{
+				Presenter:  &countingPresenter{resp: test.resp},
+				DefaultTTL: test.defaultTTL,
+				Now:        clock.Now,
+			}
+			for i, step := range test.steps {
+				clock.now = clock.now.Add(step.advance)
+				req := httptest.NewRequest(step.method, step.path, nil)
+				if step.host != "" {
+					req.Host = step.host
+				}
+				if step.reqHeader != nil {
+					req.Header = step.reqHeader
+				}
+
+				gotResp := sut.PresentHTTP(req)
+
+				if got, want := string(gotResp.Body), step.wantBody; got != want {
+					t.Errorf("step %d: got body: %s, wanted: %s", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+// TestCacheEviction tests that the least recently used responses get
+// evicted once the cache is full and that statistics are kept.
+func TestCacheEviction(t *testing.T) {
+	presenter := httphandler.PresenterFunc(func(r *http.Request) httphandler.Response {
+		return httphandler.Response{StatusCode: 200, Body: []byte(r.URL.Path)}
+	})
+	sut := &httphandler.Cache{
+		Presenter:  presenter,
+		DefaultTTL: time.Hour,
+		MaxEntries: 3,
+		MaxBytes:   8,
+	}
+	for _, path := range []string{"/a", "/b", "/c", "/a", "/d", "/b", "/a", "/this-is-too-big"} {
+		sut.PresentHTTP(httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	want := httphandler.CacheStats{Hits: 2, Misses: 6, Evictions: 2, Entries: 3, Bytes: 6}
+	if got := sut.Stats(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got stats %+v, wanted %+v", got, want)
+	}
+}
+
+// TestCacheCopies tests that mutating a response from the cache does
+// not change what is cached.
+func TestCacheCopies(t *testing.T) {
+	sut := &httphandler.Cache{
+		Presenter: httphandler.PresenterFunc(func(r *http.Request) httphandler.Response {
+			return httphandler.Response{StatusCode: 200, Header: http.Header{"X": {"1"}}, Body: []byte("abc")}
+		}),
+		DefaultTTL: time.Hour,
+	}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	sut.PresentHTTP(req)
+	resp := sut.PresentHTTP(req)
+	resp.Header.Set("X", "2")
+	resp.Body[0] = 'z'
+
+	resp = sut.PresentHTTP(req)
+
+	if got, want := resp.Header.Get("X"), "1"; got != want {
+		t.Errorf("got header %s, wanted %s", got, want)
+	}
+	if got, want := string(resp.Body), "abc"; got != want {
+		t.Errorf("got body: %s, wanted: %s", got, want)
+	}
+	if got, want := resp.Header.Get("Age"), "0"; got != want {
+		t.Errorf("got age %s, wanted %s", got, want)
+	}
+}
+
+// TestCacheStaleWhileRevalidate tests that a stale response is served
+// while a fresh one is fetched in the background.
+func TestCacheStaleWhileRevalidate(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)}
+	sut := &httphandler.Cache{
+		Presenter: &countingPresenter{resp: httphandler.Response{
+			StatusCode: 200,
+			Header:     http.Header{"Cache-Control": {"max-age=10, stale-while-revalidate=30"}},
+		}},
+		Now: clock.Now,
+	}
+	get := func() string {
+		return string(sut.PresentHTTP(httptest.NewRequest(http.MethodGet, "/swr", nil)).Body)
+	}
+
+	if got, want := get(), "call 1"; got != want {
+		t.Fatalf("got body: %s, wanted: %s", got, want)
+	}
+	clock.now = clock.now.Add(15 * time.Second)
+	if got, want := get(), "call 1"; got != want {
+		t.Fatalf("got body: %s, wanted the stale body %s", got, want)
+	}
+	deadline := time.Now().Add(time.Second)
+	for get() != "call 2" {
+		if time.Now().After(deadline) {
+			t.Fatalf("the response was never revalidated")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	clock.now = clock.now.Add(41 * time.Second)
+	if got, want := get(), "call 3"; got != want {
+		t.Errorf("got body: %s, wanted: %s", got, want)
+	}
+	if got, want := sut.Stats().Stale, uint64(1); got < want {
+		t.Errorf("got %d stale responses, wanted at least %d", got, want)
+	}
+}
+
+// TestCacheStaleIfError tests that a stale response is served in place
+// of a failed fetch while the error is still returned, and that other
+// responses which come with an error (like a 404) are not replaced.
+func TestCacheStaleIfError(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)}
+	backend := "up"
+	sut := &httphandler.Cache{
+		ErrPresenter: httphandler.ErrPresenterFunc(func(r *http.Request) (httphandler.Response, error) {
+			switch backend {
+			case "down":
+				return httphandler.Response{}, errors.New("backend is down")
+			case "gone":
+				return httphandler.Response{StatusCode: 404, Body: []byte("not found")}, errors.New("resource deleted")
+			}
+			return httphandler.Response{StatusCode: 200, Body: []byte("good data")}, nil
+		}),
+		DefaultTTL:   10 * time.Second,
+		StaleIfError: time.Minute,
+		Now:          clock.Now,
+	}
+	tests := []struct {
+		advance    time.Duration
+		backend    string
+		wantBody   string
+		wantErrMsg string
+	}{
+		{advance: 0, backend: "up", wantBody: "good data", wantErrMsg: "<nil>"},
+		{advance: 20 * time.Second, backend: "down", wantBody: "good data", wantErrMsg: "backend is down"},
+		{advance: 0, backend: "gone", wantBody: "not found", wantErrMsg: "resource deleted"},
+		{advance: 49 * time.Second, backend: "down", wantBody: "good data", wantErrMsg: "backend is down"},
+		{advance: time.Second, backend: "down", wantBody: "", wantErrMsg: "backend is down"},
+	}
+	for i, test := range tests {
+		clock.now = clock.now.Add(test.advance)
+		backend = test.backend
+
+		gotResp, gotErr := sut.ErrPresentHTTP(httptest.NewRequest(http.MethodGet, "/sie", nil))
+
+		if got, want := string(gotResp.Body), test.wantBody; got != want {
+			t.Errorf("step %d: got body: %s, wanted: %s", i, got, want)
+		}
+		if got, want := fmt.Sprintf("%v", gotErr), test.wantErrMsg; got != want {
+			t.Errorf("step %d: got error msg: %s, wanted error msg: %s", i, got, want)
+		}
+	}
+}
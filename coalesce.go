@@ -0,0 +1,148 @@
+package httphandler
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// Coalesce is a Presenter which collapses concurrent GET and HEAD
+// requests with the same key into a single call to another Presenter
+// and hands every one of those requests its own copy of the response.
+// Requests with other methods are not safe to merge so they are
+// passed straight through and so are requests with an Authorization
+// or Cookie header (whose responses are likely to be personalized)
+// unless Key is set. A response is only handed to the requests whose
+// headers match the request it was made for in the fields listed in
+// its Vary header. The other requests get their own call. A streaming
+// body is read into memory so that it can be shared. A Coalesce must
+// not be copied after first use.
+type Coalesce struct {
+	Presenter Presenter
+	// Key returns the key which decides which requests are the
+	// same. It defaults to the request's method, host and URL. It is
+	// called while holding the Coalesce's lock so it must not call
+	// back into the Coalesce.
+	Key func(*http.Request) string
+	// HandleErr is called if reading a streaming body fails.
+	HandleErr func(*http.Request, error)
+
+	mu    sync.Mutex
+	calls map[string]*coalesceCall
+}
+
+// coalesceCall is an in-flight call to the wrapped Presenter.
+type coalesceCall struct {
+	// req is the request the call was made for.
+	req      *http.Request
+	done     chan struct{}
+	cancel   context.CancelFunc
+	waiters  int
+	resp     Response
+	panicked bool
+	panicVal interface{}
+}
+
+// PresentHTTP waits for the response of an in-flight call for the
+// same key or starts a new one. The call runs with a context which is
+// only cancelled once every request waiting on it has been cancelled,
+// so one impatient client does not spoil the response for the rest.
+// A request which is cancelled while waiting gets the zero Response.
+func (c *Coalesce) PresentHTTP(r *http.Request) Response {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return c.Presenter.PresentHTTP(r)
+	}
+	if c.Key == nil && (r.Header.Get("Authorization") != "" || r.Header.Get("Cookie") != "") {
+		return c.Presenter.PresentHTTP(r)
+	}
+	c.mu.Lock()
+	key := requestKey(r)
+	if c.Key != nil {
+		key = c.Key(r)
+	}
+	if c.calls == nil {
+		c.calls = map[string]*coalesceCall{}
+	}
+	call, ok := c.calls[key]
+	if !ok {
+		ctx, cancel := context.WithCancel(context.WithoutCancel(r.Context()))
+		call = &coalesceCall{req: r, done: make(chan struct{}), cancel: cancel}
+		c.calls[key] = call
+		go c.run(key, call, r.WithContext(ctx))
+	}
+	call.waiters++
+	c.mu.Unlock()
+
+	select {
+	case <-call.done:
+		if call.panicked {
+			panic(call.panicVal)
+		}
+		if !varyMatches(call.resp.Header, call.req, r) {
+			return c.Presenter.PresentHTTP(r)
+		}
+		return copyResponse(call.resp)
+	case <-r.Context().Done():
+		c.mu.Lock()
+		call.waiters--
+		if call.waiters == 0 {
+			if c.calls[key] == call {
+				delete(c.calls, key)
+			}
+			call.cancel()
+		}
+		c.mu.Unlock()
+		return Response{}
+	}
+}
+
+// run calls the wrapped Presenter. A panic is passed on to everyone
+// waiting on the call so that it can be recovered further up.
+func (c *Coalesce) run(key string, call *coalesceCall, r *http.Request) {
+	defer func() {
+		if v := recover(); v != nil {
+			call.panicked = true
+			call.panicVal = v
+		}
+		c.mu.Lock()
+		if c.calls[key] == call {
+			delete(c.calls, key)
+		}
+		c.mu.Unlock()
+		call.cancel()
+		close(call.done)
+	}()
+	resp := c.Presenter.PresentHTTP(r)
+	if resp.Stream != nil {
+		rest, err := io.ReadAll(resp.Stream)
+		if cerr := closeStream(resp); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			if c.HandleErr != nil {
+				c.HandleErr(r, err)
+			}
+			resp = Response{}
+		} else {
+			resp.Body = append(append([]byte{}, resp.Body...), rest...)
+			resp.Stream = nil
+		}
+	}
+	call.resp = resp
+}
+
+// varyMatches reports whether a response made for one request can be
+// used for another according to the response's Vary header.
+func varyMatches(header http.Header, made, r *http.Request) bool {
+	if made == r {
+		return true
+	}
+	vary := varyFields(header)
+	for _, field := range vary {
+		if field == "*" {
+			return false
+		}
+	}
+	return varyKey("", vary, made) == varyKey("", vary, r)
+}
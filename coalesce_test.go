@@ -0,0 +1,272 @@
+package httphandler_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/lag13/httphandler"
+)
+
+// TestCoalesce tests that concurrent requests for the same key share
+// one call to the presenter and get their own copies of the response.
+func TestCoalesce(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	var arrived sync.WaitGroup
+	sut := &httphandler.Coalesce{
+		Presenter: httphandler.PresenterFunc(func(r *http.Request) httphandler.Response {
+			atomic.AddInt32(&calls, 1)
+			<-release
+			return httphandler.Response{
+				StatusCode: 200,
+				Header:     http.Header{"X": {"1"}},
+				Body:       []byte("shared "),
+				Stream:     strings.NewReader("body"),
+			}
+		}),
+		Key: func(r *http.Request) string {
+			arrived.Done()
+			return r.URL.Path
+		},
+	}
+	const n = 10
+	arrived.Add(n)
+	resps := make([]httphandler.Response, n)
+	var done sync.WaitGroup
+	for i := 0; i < n; i++ {
+		done.Add(1)
+		go func(i int) {
+			defer done.Done()
+			resps[i] = sut.PresentHTTP(httptest.NewRequest(http.MethodGet, "/hot", nil))
+		}(i)
+	}
+	// Key is called with the lock held which registers the request
+	// as a waiter so once every request has called it they are all
+	// waiting on the same call.
+	arrived.Wait()
+	close(release)
+	done.Wait()
+
+	if got, want := atomic.LoadInt32(&calls), int32(1); got != want {
+		t.Errorf("presenter was called %d times, wanted %d", got, want)
+	}
+	resps[0].Header.Set("X", "2")
+	resps[0].Body[0] = 'S'
+	for i, resp := range resps[1:] {
+		if got, want := resp.Header.Get("X"), "1"; got != want {
+			t.Errorf("response %d: got header %s, wanted %s", i+1, got, want)
+		}
+		if got, want := string(resp.Body), "shared body"; got != want {
+			t.Errorf("response %d: got body: %s, wanted: %s", i+1, got, want)
+		}
+		if resp.Stream != nil {
+			t.Errorf("response %d: got a stream, wanted it to be buffered", i+1)
+		}
+	}
+}
+
+// TestCoalesceCancel tests that a waiter whose context is cancelled
+// stops waiting without affecting the others.
+func TestCoalesceCancel(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	arrived := make(chan struct{}, 2)
+	var callCtx context.Context
+	sut := &httphandler.Coalesce{
+		Presenter: httphandler.PresenterFunc(func(r *http.Request) httphandler.Response {
+			callCtx = r.Context()
+			close(started)
+			<-release
+			return httphandler.Response{StatusCode: 200, Body: []byte("done")}
+		}),
+		Key: func(r *http.Request) string {
+			arrived <- struct{}{}
+			return r.URL.Path
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	impatient := make(chan httphandler.Response)
+	go func() {
+		impatient <- sut.PresentHTTP(httptest.NewRequest(http.MethodGet, "/slow", nil).WithContext(ctx))
+	}()
+	<-arrived
+	<-started
+	patient := make(chan httphandler.Response)
+	go func() {
+		patient <- sut.PresentHTTP(httptest.NewRequest(http.MethodGet, "/slow", nil))
+	}()
+	<-arrived
+
+	cancel()
+
+	if got := <-impatient; got.StatusCode != 0 || got.Body != nil {
+		t.Errorf("got response %+v for the cancelled request, wanted the zero value", got)
+	}
+	if err := callCtx.Err(); err != nil {
+		t.Errorf("the call was cancelled while someone was still waiting: %v", err)
+	}
+	close(release)
+	if got, want := string((<-patient).Body), "done"; got != want {
+		t.Errorf("got body: %s, wanted: %s", got, want)
+	}
+}
+
+// TestCoalescePanic tests that a panic in the presenter reaches the
+// waiting requests.
+func TestCoalescePanic(t *testing.T) {
+	sut := &httphandler.Coalesce{
+		Presenter: httphandler.PresenterFunc(func(r *http.Request) httphandler.Response {
+			panic("oh no")
+		}),
+	}
+	defer func() {
+		if got, want := recover(), "oh no"; got != want {
+			t.Errorf("got panic value %v, wanted %v", got, want)
+		}
+	}()
+
+	sut.PresentHTTP(httptest.NewRequest(http.MethodGet, "/panic", nil))
+}
+
+// TestCoalescePassesThrough tests that requests which are not GET or
+// HEAD or which are likely to get personalized responses are never
+// merged.
+func TestCoalescePassesThrough(t *testing.T) {
+	tests := []struct {
+		name       string
+		newRequest func(i int) *http.Request
+		wantBody   string
+	}{
+		{
+			name: "unsafe methods",
+			newRequest: func(i int) *http.Request {
+				return httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(fmt.Sprintf("order %d", i)))
+			},
+			wantBody: "order %d",
+		},
+		{
+			name: "authorized requests",
+			newRequest: func(i int) *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "/me", nil)
+				req.Header.Set("Authorization", fmt.Sprintf("user %d", i))
+				return req
+			},
+			wantBody: "user %d",
+		},
+		{
+			name: "requests with cookies",
+			newRequest: func(i int) *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "/me", nil)
+				req.Header.Set("Cookie", fmt.Sprintf("session=%d", i))
+				return req
+			},
+			wantBody: "session=%d",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var calls int32
+			release := make(chan struct{})
+			var started sync.WaitGroup
+			sut := &httphandler.Coalesce{
+				Presenter: httphandler.PresenterFunc(func(r *http.Request) httphandler.Response {
+					atomic.AddInt32(&calls, 1)
+					started.Done()
+					<-release
+					body, _ := io.ReadAll(r.Body)
+					body = append(body, r.Header.Get("Authorization")+r.Header.Get("Cookie")...)
+					return httphandler.Response{StatusCode: 200, Body: body}
+				}),
+			}
+			const n = 2
+			started.Add(n)
+			resps := make([]httphandler.Response, n)
+			var done sync.WaitGroup
+			for i := 0; i < n; i++ {
+				done.Add(1)
+				go func(i int) {
+					defer done.Done()
+					resps[i] = sut.PresentHTTP(test.newRequest(i))
+				}(i)
+			}
+			// Both calls being in flight at once shows that neither
+			// waited on the other.
+			started.Wait()
+			close(release)
+			done.Wait()
+
+			if got, want := atomic.LoadInt32(&calls), int32(n); got != want {
+				t.Errorf("presenter was called %d times, wanted %d", got, want)
+			}
+			for i, resp := range resps {
+				if got, want := string(resp.Body), fmt.Sprintf(test.wantBody, i); got != want {
+					t.Errorf("response %d: got body: %s, wanted: %s", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+// TestCoalesceVary tests that a response is only shared with the
+// requests matching its Vary header and that the others get their
+// own call.
+func TestCoalesceVary(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	started := make(chan struct{}, 3)
+	arrived := make(chan struct{}, 3)
+	sut := &httphandler.Coalesce{
+		Presenter: httphandler.PresenterFunc(func(r *http.Request) httphandler.Response {
+			atomic.AddInt32(&calls, 1)
+			started <- struct{}{}
+			<-release
+			return httphandler.Response{
+				StatusCode: 200,
+				Header:     http.Header{"Vary": {"Accept-Encoding"}},
+				Body:       []byte("encoding " + r.Header.Get("Accept-Encoding")),
+			}
+		}),
+		Key: func(r *http.Request) string {
+			arrived <- struct{}{}
+			return r.URL.Path
+		},
+	}
+	present := func(acceptEncoding string) chan httphandler.Response {
+		resps := make(chan httphandler.Response)
+		req := httptest.NewRequest(http.MethodGet, "/page", nil)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		go func() { resps <- sut.PresentHTTP(req) }()
+		return resps
+	}
+	first := present("gzip")
+	<-arrived
+	<-started
+	same := present("gzip")
+	<-arrived
+	different := present("")
+	<-arrived
+
+	close(release)
+
+	if got, want := string((<-first).Body), "encoding gzip"; got != want {
+		t.Errorf("first request: got body: %s, wanted: %s", got, want)
+	}
+	if got, want := string((<-same).Body), "encoding gzip"; got != want {
+		t.Errorf("matching request: got body: %s, wanted: %s", got, want)
+	}
+	if got, want := string((<-different).Body), "encoding "; got != want {
+		t.Errorf("different request: got body: %s, wanted: %s", got, want)
+	}
+	if got, want := atomic.LoadInt32(&calls), int32(2); got != want {
+		t.Errorf("presenter was called %d times, wanted %d", got, want)
+	}
+}
@@ -0,0 +1,232 @@
+package httphandler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Compress is a Presenter which compresses the response of another
+// Presenter with gzip or deflate depending on the request's
+// Accept-Encoding header. Buffered bodies smaller than MinSize are
+// left alone, as are responses which already have a Content-Encoding,
+// responses whose Content-Type is already compressed (images, video,
+// archives and so on) or is text/event-stream, and streaming bodies
+// without a Content-Type (since it could no longer be sniffed once
+// compressed).
+type Compress struct {
+	Presenter Presenter
+	MinSize   int
+	// Level is the compression level as understood by
+	// compress/flate. Zero means the default level.
+	Level int
+}
+
+// PresentHTTP returns the response from a Presenter, compressed if
+// the client accepts it.
+func (c Compress) PresentHTTP(r *http.Request) Response {
+	resp := c.Presenter.PresentHTTP(r)
+	if !c.compressible(resp) {
+		return resp
+	}
+	resp.Header = cloneHeader(resp.Header)
+	addVary(resp.Header, "Accept-Encoding")
+	coding := negotiateEncoding(r.Header.Values("Accept-Encoding"))
+	if coding == "" {
+		return resp
+	}
+	if resp.Stream == nil {
+		return c.compressBody(resp, coding)
+	}
+	return c.compressStream(resp, coding)
+}
+
+// compressible reports whether it makes sense to compress resp.
+func (c Compress) compressible(resp Response) bool {
+	statusCode := resp.StatusCode
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+	if !bodyAllowed(statusCode) || statusCode == http.StatusPartialContent {
+		return false
+	}
+	if resp.Header.Get("Content-Encoding") != "" {
+		return false
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if resp.Stream == nil {
+		if len(resp.Body) < c.MinSize || len(resp.Body) == 0 {
+			return false
+		}
+		if contentType == "" {
+			contentType = http.DetectContentType(resp.Body)
+		}
+	} else if contentType == "" {
+		return false
+	}
+	// Compressing an event stream would hold events back until the
+	// compressor's buffer fills up.
+	if mediaType, _, _ := strings.Cut(contentType, ";"); strings.TrimSpace(mediaType) == "text/event-stream" {
+		return false
+	}
+	return !alreadyCompressed(contentType)
+}
+
+// compressBody compresses a buffered body. The original response is
+// returned if compressing it does not make it any smaller.
+func (c Compress) compressBody(resp Response, coding string) Response {
+	var buf bytes.Buffer
+	cw, err := c.newWriter(&buf, coding)
+	if err != nil {
+		return resp
+	}
+	if _, err := cw.Write(resp.Body); err != nil {
+		return resp
+	}
+	if err := cw.Close(); err != nil || buf.Len() >= len(resp.Body) {
+		return resp
+	}
+	if resp.Header.Get("Content-Type") == "" {
+		resp.Header.Set("Content-Type", http.DetectContentType(resp.Body))
+	}
+	setEncoded(resp.Header, coding)
+	resp.Header.Set("Content-Length", strconv.Itoa(buf.Len()))
+	resp.Body = buf.Bytes()
+	return resp
+}
+
+// compressStream replaces the body with a stream which compresses it
+// as it is read. Closing the new stream closes the original one.
+func (c Compress) compressStream(resp Response, coding string) Response {
+	pr, pw := io.Pipe()
+	cw, err := c.newWriter(pw, coding)
+	if err != nil {
+		return resp
+	}
+	src := resp.Stream
+	body := resp.Body
+	go func() {
+		_, err := cw.Write(body)
+		if err == nil {
+			_, err = io.Copy(flushingCompressor{cw}, src)
+		}
+		if cerr := cw.Close(); err == nil {
+			err = cerr
+		}
+		if closer, ok := src.(io.Closer); ok {
+			if cerr := closer.Close(); err == nil {
+				err = cerr
+			}
+		}
+		pw.CloseWithError(err)
+	}()
+	setEncoded(resp.Header, coding)
+	resp.Header.Del("Content-Length")
+	resp.Body = nil
+	resp.Stream = pr
+	return resp
+}
+
+// compressor is implemented by both gzip.Writer and zlib.Writer.
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+}
+
+// flushingCompressor flushes after every write so a stream gets
+// passed along as it is produced rather than whenever the compressor
+// has gathered enough of it.
+type flushingCompressor struct {
+	compressor
+}
+
+func (f flushingCompressor) Write(p []byte) (int, error) {
+	n, err := f.compressor.Write(p)
+	if err == nil {
+		err = f.compressor.Flush()
+	}
+	return n, err
+}
+
+// newWriter returns a writer which compresses with the given coding.
+func (c Compress) newWriter(w io.Writer, coding string) (compressor, error) {
+	level := c.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	if coding == "gzip" {
+		return gzip.NewWriterLevel(w, level)
+	}
+	return zlib.NewWriterLevel(w, level)
+}
+
+// setEncoded records that the body was encoded with coding. A strong
+// ETag becomes weak since the bytes it described have changed.
+func setEncoded(h http.Header, coding string) {
+	h.Set("Content-Encoding", coding)
+	if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		h.Set("ETag", "W/"+etag)
+	}
+}
+
+// negotiateEncoding returns "gzip", "deflate", or "" (meaning the
+// body should not be compressed) based off an Accept-Encoding header.
+func negotiateEncoding(acceptEncoding []string) string {
+	qualities := map[string]float64{}
+	for _, elem := range strings.Split(strings.Join(acceptEncoding, ","), ",") {
+		coding, rawParams, _ := strings.Cut(elem, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "" {
+			continue
+		}
+		params := map[string]string{}
+		for _, param := range strings.Split(rawParams, ";") {
+			name, value, _ := strings.Cut(param, "=")
+			params[strings.ToLower(strings.TrimSpace(name))] = strings.TrimSpace(value)
+		}
+		if q, ok := parseQ(params); ok {
+			qualities[coding] = q
+		}
+	}
+	best, bestQ := "", 0.0
+	for _, coding := range []string{"gzip", "deflate"} {
+		q, ok := qualities[coding]
+		if !ok {
+			q = qualities["*"]
+		}
+		if q > bestQ {
+			best, bestQ = coding, q
+		}
+	}
+	return best
+}
+
+// alreadyCompressed reports whether content of the given type is
+// already compressed so compressing it again would be wasted effort.
+func alreadyCompressed(contentType string) bool {
+	contentType = strings.ToLower(contentType)
+	if mediaType, _, ok := strings.Cut(contentType, ";"); ok {
+		contentType = mediaType
+	}
+	contentType = strings.TrimSpace(contentType)
+	switch {
+	case contentType == "image/svg+xml":
+		return false
+	case strings.HasPrefix(contentType, "image/"),
+		strings.HasPrefix(contentType, "video/"),
+		strings.HasPrefix(contentType, "audio/"),
+		strings.HasPrefix(contentType, "font/woff"):
+		return true
+	}
+	switch contentType {
+	case "application/zip", "application/gzip", "application/x-gzip",
+		"application/x-bzip2", "application/x-xz", "application/zstd",
+		"application/x-7z-compressed", "application/x-rar-compressed":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,185 @@
+package httphandler_test
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/lag13/httphandler"
+)
+
+// decompress returns the decompressed body of a response.
+func decompress(t *testing.T, coding string, body io.Reader) string {
+	t.Helper()
+	var r io.Reader
+	var err error
+	switch coding {
+	case "gzip":
+		r, err = gzip.NewReader(body)
+	case "deflate":
+		r, err = zlib.NewReader(body)
+	default:
+		r = body
+	}
+	if err != nil {
+		t.Fatalf("creating %s reader: %v", coding, err)
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("decompressing body: %v", err)
+	}
+	return string(b)
+}
+
+// TestCompress tests that Compress compresses response bodies when
+// it is worth doing and the client accepts it.
+func TestCompress(t *testing.T) {
+	text := strings.Repeat("hello world! ", 100)
+	tests := []struct {
+		name           string
+		resp           httphandler.Response
+		acceptEncoding string
+		wantEncoding   string
+		wantVary       []string
+		wantETag       string
+		wantBody       string
+	}{
+		{
+			name:           "gzip",
+			resp:           httphandler.Response{Header: http.Header{"Etag": {`"abc"`}}, Body: []byte(text)},
+			acceptEncoding: "gzip, deflate",
+			wantEncoding:   "gzip",
+			wantVary:       []string{"Accept-Encoding"},
+			wantETag:       `W/"abc"`,
+			wantBody:       text,
+		},
+		{
+			name:           "deflate is preferred by the client",
+			resp:           httphandler.Response{Body: []byte(text)},
+			acceptEncoding: "gzip;q=0.5, deflate",
+			wantEncoding:   "deflate",
+			wantVary:       []string{"Accept-Encoding"},
+			wantBody:       text,
+		},
+		{
+			name:           "wildcard",
+			resp:           httphandler.Response{Body: []byte(text)},
+			acceptEncoding: "*",
+			wantEncoding:   "gzip",
+			wantVary:       []string{"Accept-Encoding"},
+			wantBody:       text,
+		},
+		{
+			name:           "client does not accept compression",
+			resp:           httphandler.Response{Body: []byte(text)},
+			acceptEncoding: "gzip;q=0, br",
+			wantEncoding:   "",
+			wantVary:       []string{"Accept-Encoding"},
+			wantBody:       text,
+		},
+		{
+			name:           "small bodies are left alone",
+			resp:           httphandler.Response{Body: []byte("hi")},
+			acceptEncoding: "gzip",
+			wantEncoding:   "",
+			wantVary:       nil,
+			wantBody:       "hi",
+		},
+		{
+			name: "compressed content types are left alone",
+			resp: httphandler.Response{
+				Header: http.Header{"Content-Type": {"image/png"}},
+				Body:   []byte(text),
+			},
+			acceptEncoding: "gzip",
+			wantEncoding:   "",
+			wantVary:       nil,
+			wantBody:       text,
+		},
+		{
+			name: "existing vary headers are kept",
+			resp: httphandler.Response{
+				Header: http.Header{"Content-Type": {"text/plain"}, "Vary": {"Accept"}},
+				Body:   []byte(text),
+			},
+			acceptEncoding: "gzip",
+			wantEncoding:   "gzip",
+			wantVary:       []string{"Accept", "Accept-Encoding"},
+			wantBody:       text,
+		},
+		{
+			name: "streams are compressed",
+			resp: httphandler.Response{
+				Header: http.Header{"Content-Type": {"text/plain"}, "Content-Length": {"1300"}},
+				Body:   []byte(text[:100]),
+				Stream: strings.NewReader(text[100:]),
+			},
+			acceptEncoding: "gzip",
+			wantEncoding:   "gzip",
+			wantVary:       []string{"Accept-Encoding"},
+			wantBody:       text,
+		},
+		{
+			name: "event streams are left alone",
+			resp: httphandler.Response{
+				Header: http.Header{"Content-Type": {"text/event-stream"}},
+				Stream: strings.NewReader(text),
+			},
+			acceptEncoding: "gzip",
+			wantEncoding:   "",
+			wantVary:       nil,
+			wantBody:       text,
+		},
+		{
+			name: "streams without a content type are left alone",
+			resp: httphandler.Response{
+				Stream: strings.NewReader(text),
+			},
+			acceptEncoding: "gzip",
+			wantEncoding:   "",
+			wantVary:       nil,
+			wantBody:       text,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sut := httphandler.Writer{
+				Presenter: httphandler.Compress{
+					Presenter: httphandler.PresenterFunc(func(*http.Request) httphandler.Response {
+						return test.resp
+					}),
+					MinSize: 100,
+				},
+			}
+			req := httptest.NewRequest(http.MethodGet, "/compress", nil)
+			req.Header.Set("Accept-Encoding", test.acceptEncoding)
+			w := httptest.NewRecorder()
+
+			sut.ServeHTTP(w, req)
+
+			if got, want := w.Header().Get("Content-Encoding"), test.wantEncoding; got != want {
+				t.Errorf("got content encoding %q, wanted %q", got, want)
+			}
+			if got, want := w.Header().Values("Vary"), test.wantVary; !reflect.DeepEqual(got, want) {
+				t.Errorf("got vary %v, wanted %v", got, want)
+			}
+			if got, want := w.Header().Get("ETag"), test.wantETag; got != want {
+				t.Errorf("got etag %q, wanted %q", got, want)
+			}
+			if test.wantEncoding != "" && test.resp.Stream == nil {
+				if got, want := w.Header().Get("Content-Length"), len(w.Body.Bytes()); got != strconv.Itoa(want) {
+					t.Errorf("got content length %s, wanted %d", got, want)
+				}
+			}
+			if got, want := decompress(t, test.wantEncoding, w.Body), test.wantBody; got != want {
+				t.Errorf("got body: %s, wanted: %s", got, want)
+			}
+		})
+	}
+}
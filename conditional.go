@@ -0,0 +1,135 @@
+package httphandler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Conditional is a Presenter which evaluates the conditional request
+// headers (If-Match, If-None-Match, If-Modified-Since and
+// If-Unmodified-Since) as described in RFC 9110 section 13.
+//
+// For GET and HEAD requests the validators come from the ETag and
+// Last-Modified headers of the response that Presenter returns. A
+// strong ETag is computed from the body if the response has none. A
+// 304 or 412 response is returned instead of that response when a
+// precondition says so.
+//
+// For other methods the preconditions must be checked before the
+// request changes anything so the validators come from Current
+// instead. If Current is nil then those requests are passed straight
+// through.
+type Conditional struct {
+	Presenter Presenter
+	// Current returns the validators of the current representation
+	// of the requested resource and whether it exists at all. An
+	// empty etag or zero lastModified means there is no such
+	// validator.
+	Current func(*http.Request) (etag string, lastModified time.Time, exists bool)
+}
+
+// PresentHTTP returns the response from a Presenter unless a
+// precondition of the request fails.
+func (c Conditional) PresentHTTP(r *http.Request) Response {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		if c.Current == nil {
+			return c.Presenter.PresentHTTP(r)
+		}
+		etag, lastModified, exists := c.Current(r)
+		if statusCode := evalPreconditions(r, etag, lastModified, exists); statusCode != 0 {
+			return Response{StatusCode: statusCode}
+		}
+		return c.Presenter.PresentHTTP(r)
+	}
+	resp := c.Presenter.PresentHTTP(r)
+	if resp.StatusCode != 0 && (resp.StatusCode < 200 || resp.StatusCode > 299) {
+		return resp
+	}
+	resp.Header = cloneHeader(resp.Header)
+	if resp.Header.Get("ETag") == "" && resp.Stream == nil {
+		resp.Header.Set("ETag", computeETag(resp.Body))
+	}
+	lastModified, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	statusCode := evalPreconditions(r, resp.Header.Get("ETag"), lastModified, true)
+	if statusCode == 0 {
+		return resp
+	}
+	// The response is being replaced so its stream will never be
+	// read.
+	closeStream(resp)
+	header := http.Header{}
+	if statusCode == http.StatusNotModified {
+		for _, name := range []string{"Cache-Control", "Content-Location", "Date", "ETag", "Expires", "Last-Modified", "Vary"} {
+			if values := resp.Header.Values(name); len(values) > 0 {
+				header[http.CanonicalHeaderKey(name)] = values
+			}
+		}
+	}
+	return Response{StatusCode: statusCode, Header: header}
+}
+
+// computeETag returns a strong ETag for body.
+func computeETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// evalPreconditions evaluates the request's preconditions against the
+// validators of the requested resource in the order given by RFC 9110
+// section 13.2.2. It returns 304 or 412 if a precondition fails and 0
+// otherwise.
+func evalPreconditions(r *http.Request, etag string, lastModified time.Time, exists bool) int {
+	safe := r.Method == http.MethodGet || r.Method == http.MethodHead
+	if ifMatch := r.Header.Values("If-Match"); len(ifMatch) > 0 {
+		if !exists || !etagListMatches(ifMatch, etag, true) {
+			return http.StatusPreconditionFailed
+		}
+	} else if ius, err := http.ParseTime(r.Header.Get("If-Unmodified-Since")); err == nil && exists && !lastModified.IsZero() {
+		if lastModified.Truncate(time.Second).After(ius) {
+			return http.StatusPreconditionFailed
+		}
+	}
+	if ifNoneMatch := r.Header.Values("If-None-Match"); len(ifNoneMatch) > 0 {
+		if exists && etagListMatches(ifNoneMatch, etag, false) {
+			if safe {
+				return http.StatusNotModified
+			}
+			return http.StatusPreconditionFailed
+		}
+	} else if ims, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && safe && !lastModified.IsZero() {
+		if !lastModified.Truncate(time.Second).After(ims) {
+			return http.StatusNotModified
+		}
+	}
+	return 0
+}
+
+// etagListMatches reports whether an If-Match or If-None-Match header
+// matches etag, using the strong or weak comparison function.
+func etagListMatches(values []string, etag string, strong bool) bool {
+	for _, value := range values {
+		for _, candidate := range strings.Split(value, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" {
+				return true
+			}
+			if etag != "" && etagsMatch(candidate, etag, strong) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// etagsMatch compares two entity tags. The strong comparison requires
+// that neither is weak.
+func etagsMatch(a, b string, strong bool) bool {
+	aWeak, bWeak := strings.HasPrefix(a, "W/"), strings.HasPrefix(b, "W/")
+	if strong && (aWeak || bWeak) {
+		return false
+	}
+	return strings.TrimPrefix(a, "W/") == strings.TrimPrefix(b, "W/")
+}
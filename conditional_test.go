@@ -0,0 +1,221 @@
+package httphandler_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lag13/httphandler"
+)
+
+// TestConditionalSafe tests that Conditional evaluates preconditions
+// of GET and HEAD requests against the response of the presenter.
+func TestConditionalSafe(t *testing.T) {
+	lastModified := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name           string
+		resp           httphandler.Response
+		reqHeader      http.Header
+		wantStatusCode int
+		wantETag       string
+		wantBody       string
+	}{
+		{
+			name:           "no preconditions and an ETag is computed",
+			resp:           httphandler.Response{Body: []byte("hello")},
+			reqHeader:      http.Header{},
+			wantStatusCode: 0,
+			wantETag:       `"2cf24dba5fb0a30e26e83b2ac5b9e29e"`,
+			wantBody:       "hello",
+		},
+		{
+			name:           "If-None-Match matches the computed ETag",
+			resp:           httphandler.Response{Body: []byte("hello")},
+			reqHeader:      http.Header{"If-None-Match": {`"nope", "2cf24dba5fb0a30e26e83b2ac5b9e29e"`}},
+			wantStatusCode: http.StatusNotModified,
+			wantETag:       `"2cf24dba5fb0a30e26e83b2ac5b9e29e"`,
+			wantBody:       "",
+		},
+		{
+			name: "If-None-Match uses the weak comparison",
+			resp: httphandler.Response{
+				Header: http.Header{"Etag": {`W/"v1"`}},
+				Body:   []byte("hello"),
+			},
+			reqHeader:      http.Header{"If-None-Match": {`"v1"`}},
+			wantStatusCode: http.StatusNotModified,
+			wantETag:       `W/"v1"`,
+			wantBody:       "",
+		},
+		{
+			name: "If-None-Match does not match",
+			resp: httphandler.Response{
+				Header: http.Header{"Etag": {`"v2"`}},
+				Body:   []byte("hello"),
+			},
+			reqHeader:      http.Header{"If-None-Match": {`"v1"`}},
+			wantStatusCode: 0,
+			wantETag:       `"v2"`,
+			wantBody:       "hello",
+		},
+		{
+			name: "If-Modified-Since is not modified",
+			resp: httphandler.Response{
+				Header: http.Header{"Last-Modified": {lastModified.Format(http.TimeFormat)}},
+				Body:   []byte("hello"),
+			},
+			reqHeader:      http.Header{"If-Modified-Since": {lastModified.Format(http.TimeFormat)}},
+			wantStatusCode: http.StatusNotModified,
+			wantETag:       `"2cf24dba5fb0a30e26e83b2ac5b9e29e"`,
+			wantBody:       "",
+		},
+		{
+			name: "If-Modified-Since is modified",
+			resp: httphandler.Response{
+				Header: http.Header{"Last-Modified": {lastModified.Format(http.TimeFormat)}},
+				Body:   []byte("hello"),
+			},
+			reqHeader:      http.Header{"If-Modified-Since": {lastModified.Add(-time.Hour).Format(http.TimeFormat)}},
+			wantStatusCode: 0,
+			wantETag:       `"2cf24dba5fb0a30e26e83b2ac5b9e29e"`,
+			wantBody:       "hello",
+		},
+		{
+			name: "If-None-Match takes precedence over If-Modified-Since",
+			resp: httphandler.Response{
+				Header: http.Header{
+					"Etag":          {`"v2"`},
+					"Last-Modified": {lastModified.Format(http.TimeFormat)},
+				},
+				Body: []byte("hello"),
+			},
+			reqHeader: http.Header{
+				"If-None-Match":     {`"v1"`},
+				"If-Modified-Since": {lastModified.Format(http.TimeFormat)},
+			},
+			wantStatusCode: 0,
+			wantETag:       `"v2"`,
+			wantBody:       "hello",
+		},
+		{
+			name: "If-Match fails with a weak ETag",
+			resp: httphandler.Response{
+				Header: http.Header{"Etag": {`W/"v1"`}},
+				Body:   []byte("hello"),
+			},
+			reqHeader:      http.Header{"If-Match": {`W/"v1"`}},
+			wantStatusCode: http.StatusPreconditionFailed,
+			wantETag:       "",
+			wantBody:       "",
+		},
+		{
+			name: "If-Unmodified-Since fails",
+			resp: httphandler.Response{
+				Header: http.Header{"Last-Modified": {lastModified.Format(http.TimeFormat)}},
+				Body:   []byte("hello"),
+			},
+			reqHeader:      http.Header{"If-Unmodified-Since": {lastModified.Add(-time.Second).Format(http.TimeFormat)}},
+			wantStatusCode: http.StatusPreconditionFailed,
+			wantETag:       "",
+			wantBody:       "",
+		},
+		{
+			name:           "error responses are left alone",
+			resp:           httphandler.Response{StatusCode: 404, Body: []byte("not found")},
+			reqHeader:      http.Header{"If-None-Match": {"*"}},
+			wantStatusCode: 404,
+			wantETag:       "",
+			wantBody:       "not found",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sut := httphandler.Conditional{
+				Presenter: httphandler.PresenterFunc(func(*http.Request) httphandler.Response {
+					return test.resp
+				}),
+			}
+			req := httptest.NewRequest(http.MethodGet, "/conditional", nil)
+			req.Header = test.reqHeader
+
+			gotResp := sut.PresentHTTP(req)
+
+			if got, want := gotResp.StatusCode, test.wantStatusCode; got != want {
+				t.Errorf("got status code %v, wanted %v", got, want)
+			}
+			if got, want := gotResp.Header.Get("ETag"), test.wantETag; got != want {
+				t.Errorf("got etag %s, wanted %s", got, want)
+			}
+			if got, want := string(gotResp.Body), test.wantBody; got != want {
+				t.Errorf("got body: %s, wanted: %s", got, want)
+			}
+		})
+	}
+}
+
+// TestConditionalUnsafe tests that Conditional checks preconditions of
+// other requests before calling the presenter.
+func TestConditionalUnsafe(t *testing.T) {
+	tests := []struct {
+		name           string
+		reqHeader      http.Header
+		exists         bool
+		wantStatusCode int
+		wantCalled     bool
+	}{
+		{
+			name:           "If-Match matches",
+			reqHeader:      http.Header{"If-Match": {`"v1"`}},
+			exists:         true,
+			wantStatusCode: 204,
+			wantCalled:     true,
+		},
+		{
+			name:           "If-Match does not match",
+			reqHeader:      http.Header{"If-Match": {`"v0"`}},
+			exists:         true,
+			wantStatusCode: http.StatusPreconditionFailed,
+			wantCalled:     false,
+		},
+		{
+			name:           "If-None-Match star when the resource exists",
+			reqHeader:      http.Header{"If-None-Match": {"*"}},
+			exists:         true,
+			wantStatusCode: http.StatusPreconditionFailed,
+			wantCalled:     false,
+		},
+		{
+			name:           "If-None-Match star when the resource does not exist",
+			reqHeader:      http.Header{"If-None-Match": {"*"}},
+			exists:         false,
+			wantStatusCode: 204,
+			wantCalled:     true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			called := false
+			sut := httphandler.Conditional{
+				Presenter: httphandler.PresenterFunc(func(*http.Request) httphandler.Response {
+					called = true
+					return httphandler.Response{StatusCode: 204}
+				}),
+				Current: func(*http.Request) (string, time.Time, bool) {
+					return `"v1"`, time.Time{}, test.exists
+				},
+			}
+			req := httptest.NewRequest(http.MethodPut, "/conditional", nil)
+			req.Header = test.reqHeader
+
+			gotResp := sut.PresentHTTP(req)
+
+			if got, want := gotResp.StatusCode, test.wantStatusCode; got != want {
+				t.Errorf("got status code %v, wanted %v", got, want)
+			}
+			if got, want := called, test.wantCalled; got != want {
+				t.Errorf("presenter being called was %v", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package httphandler
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCookie is returned (possibly wrapped) when a cookie value
+// cannot be decoded because it is malformed, was tampered with, was
+// encoded for a different cookie name, or has expired.
+var ErrInvalidCookie = errors.New("invalid cookie value")
+
+// CookieCodec encodes cookie values so that clients cannot forge them
+// and decodes them again when they come back on a request. By default
+// values are signed with HMAC-SHA256 which means clients can read
+// them but not change them. If Encrypt is set then values are instead
+// encrypted with AES-GCM which means clients can do neither.
+//
+// Values are always encoded with the first of Keys but are decoded
+// with any of them so keys can be rotated by adding a new key at the
+// front and removing the old one once the cookies it encoded have
+// expired. Encryption keys must be 16, 24 or 32 bytes long (to select
+// AES-128, AES-192 or AES-256).
+//
+// The time a value was encoded is part of the value so that it can be
+// rejected once it is older than MaxAge. A MaxAge of 0 means values
+// never expire.
+type CookieCodec struct {
+	Keys    [][]byte
+	Encrypt bool
+	MaxAge  time.Duration
+	// Now returns the current time. It defaults to time.Now.
+	Now func() time.Time
+}
+
+// Encode returns the encoded form of the value of the cookie called
+// name. The name is bound to the encoded value so it will not decode
+// as the value of a different cookie.
+func (c CookieCodec) Encode(name, value string) (string, error) {
+	if len(c.Keys) == 0 {
+		return "", errors.New("encoding cookie: no keys")
+	}
+	payload := make([]byte, 8, 8+len(value))
+	binary.BigEndian.PutUint64(payload, uint64(c.now().Unix()))
+	payload = append(payload, value...)
+	if !c.Encrypt {
+		data := base64.RawURLEncoding.EncodeToString(payload)
+		return data + "." + base64.RawURLEncoding.EncodeToString(cookieMAC(c.Keys[0], name, data)), nil
+	}
+	aead, err := cookieAEAD(c.Keys[0])
+	if err != nil {
+		return "", fmt.Errorf("encoding cookie: %w", err)
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(payload)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("encoding cookie: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(aead.Seal(nonce, nonce, payload, []byte(name))), nil
+}
+
+// Decode verifies (or decrypts) a value produced by Encode for the
+// cookie called name and returns the original value. An error
+// wrapping ErrInvalidCookie is returned if that cannot be done.
+func (c CookieCodec) Decode(name, encoded string) (string, error) {
+	if len(c.Keys) == 0 {
+		return "", errors.New("decoding cookie: no keys")
+	}
+	var payload []byte
+	var err error
+	if c.Encrypt {
+		payload, err = c.decrypt(name, encoded)
+	} else {
+		payload, err = c.verify(name, encoded)
+	}
+	if err != nil {
+		return "", err
+	}
+	if len(payload) < 8 {
+		return "", fmt.Errorf("%w: too short", ErrInvalidCookie)
+	}
+	created := time.Unix(int64(binary.BigEndian.Uint64(payload)), 0)
+	if c.MaxAge > 0 && c.now().Sub(created) > c.MaxAge {
+		return "", fmt.Errorf("%w: expired", ErrInvalidCookie)
+	}
+	return string(payload[8:]), nil
+}
+
+// Cookie returns a cookie called name whose value is the encoded
+// value. The cookie is restricted to HTTPS, hidden from JavaScript
+// and lasts as long as MaxAge (or the browser session if MaxAge is
+// 0). Any of that can be changed on the returned cookie before it is
+// added to a Response.
+func (c CookieCodec) Cookie(name, value string) (*http.Cookie, error) {
+	encoded, err := c.Encode(name, value)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Cookie{
+		Name:     name,
+		Value:    encoded,
+		Path:     "/",
+		MaxAge:   int(c.MaxAge / time.Second),
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}, nil
+}
+
+// ReadCookie decodes the value of the request's cookie called name.
+// It returns http.ErrNoCookie if there is no such cookie.
+func (c CookieCodec) ReadCookie(r *http.Request, name string) (string, error) {
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	return c.Decode(name, cookie.Value)
+}
+
+// verify checks the signature of a signed value and returns its
+// payload.
+func (c CookieCodec) verify(name, encoded string) ([]byte, error) {
+	data, sig, ok := strings.Cut(encoded, ".")
+	if !ok {
+		return nil, fmt.Errorf("%w: missing signature", ErrInvalidCookie)
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCookie, err)
+	}
+	for _, key := range c.Keys {
+		if hmac.Equal(mac, cookieMAC(key, name, data)) {
+			payload, err := base64.RawURLEncoding.DecodeString(data)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidCookie, err)
+			}
+			return payload, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: bad signature", ErrInvalidCookie)
+}
+
+// decrypt decrypts an encrypted value and returns its payload.
+func (c CookieCodec) decrypt(name, encoded string) ([]byte, error) {
+	sealed, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCookie, err)
+	}
+	for _, key := range c.Keys {
+		aead, err := cookieAEAD(key)
+		if err != nil {
+			return nil, fmt.Errorf("decoding cookie: %w", err)
+		}
+		if len(sealed) < aead.NonceSize() {
+			return nil, fmt.Errorf("%w: too short", ErrInvalidCookie)
+		}
+		nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+		if payload, err := aead.Open(nil, nonce, ciphertext, []byte(name)); err == nil {
+			return payload, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: decryption failed", ErrInvalidCookie)
+}
+
+func (c CookieCodec) now() time.Time {
+	if c.Now == nil {
+		return time.Now()
+	}
+	return c.Now()
+}
+
+// cookieMAC returns the signature of a cookie's encoded data. The
+// name is included so a value cannot be moved to another cookie.
+func cookieMAC(key []byte, name, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(name))
+	mac.Write([]byte{'|'})
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// cookieAEAD returns the AES-GCM cipher for key.
+func cookieAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
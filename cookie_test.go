@@ -0,0 +1,213 @@
+package httphandler_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lag13/httphandler"
+)
+
+// TestCookieCodec tests that values encoded by a CookieCodec decode
+// to the original value and that values which were tampered with,
+// encoded with an unknown key, encoded for a different cookie or have
+// expired are rejected.
+func TestCookieCodec(t *testing.T) {
+	oldKey := []byte("0123456789abcdef")
+	newKey := []byte("fedcba9876543210")
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	tamper := func(s string) string {
+		b := []byte(s)
+		if b[0] == 'A' {
+			b[0] = 'B'
+		} else {
+			b[0] = 'A'
+		}
+		return string(b)
+	}
+	tests := []struct {
+		name        string
+		encodeKeys  [][]byte
+		decodeKeys  [][]byte
+		decodeName  string
+		decodeAfter time.Duration
+		modify      func(string) string
+		wantErr     bool
+	}{
+		{
+			name:       "round trip",
+			encodeKeys: [][]byte{newKey},
+			decodeKeys: [][]byte{newKey},
+			decodeName: "session",
+		},
+		{
+			name:       "decodes with an older key",
+			encodeKeys: [][]byte{oldKey},
+			decodeKeys: [][]byte{newKey, oldKey},
+			decodeName: "session",
+		},
+		{
+			name:       "unknown key",
+			encodeKeys: [][]byte{oldKey},
+			decodeKeys: [][]byte{newKey},
+			decodeName: "session",
+			wantErr:    true,
+		},
+		{
+			name:       "tampered value",
+			encodeKeys: [][]byte{newKey},
+			decodeKeys: [][]byte{newKey},
+			decodeName: "session",
+			modify:     tamper,
+			wantErr:    true,
+		},
+		{
+			name:       "truncated value",
+			encodeKeys: [][]byte{newKey},
+			decodeKeys: [][]byte{newKey},
+			decodeName: "session",
+			modify:     func(s string) string { return s[:5] },
+			wantErr:    true,
+		},
+		{
+			name:       "different cookie name",
+			encodeKeys: [][]byte{newKey},
+			decodeKeys: [][]byte{newKey},
+			decodeName: "other",
+			wantErr:    true,
+		},
+		{
+			name:        "not yet expired",
+			encodeKeys:  [][]byte{newKey},
+			decodeKeys:  [][]byte{newKey},
+			decodeName:  "session",
+			decodeAfter: time.Hour,
+		},
+		{
+			name:        "expired",
+			encodeKeys:  [][]byte{newKey},
+			decodeKeys:  [][]byte{newKey},
+			decodeName:  "session",
+			decodeAfter: time.Hour + time.Second,
+			wantErr:     true,
+		},
+	}
+	for _, encrypt := range []bool{false, true} {
+		for _, test := range tests {
+			name := test.name
+			if encrypt {
+				name = "encrypted " + name
+			}
+			t.Run(name, func(t *testing.T) {
+				encoder := httphandler.CookieCodec{
+					Keys:    test.encodeKeys,
+					Encrypt: encrypt,
+					MaxAge:  time.Hour,
+					Now:     func() time.Time { return start },
+				}
+				decoder := encoder
+				decoder.Keys = test.decodeKeys
+				decoder.Now = func() time.Time { return start.Add(test.decodeAfter) }
+
+				encoded, err := encoder.Encode("session", "user=42")
+				if err != nil {
+					t.Fatalf("unexpected error encoding: %v", err)
+				}
+				if test.modify != nil {
+					encoded = test.modify(encoded)
+				}
+				got, err := decoder.Decode(test.decodeName, encoded)
+
+				if test.wantErr {
+					if !errors.Is(err, httphandler.ErrInvalidCookie) {
+						t.Errorf("got error %v, wanted ErrInvalidCookie", err)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("unexpected error decoding: %v", err)
+				}
+				if got != "user=42" {
+					t.Errorf("got value %q, wanted %q", got, "user=42")
+				}
+			})
+		}
+	}
+}
+
+// TestCookieCodecEncrypts tests that encrypted values do not reveal
+// the value and differ each time the same value is encoded.
+func TestCookieCodecEncrypts(t *testing.T) {
+	codec := httphandler.CookieCodec{Keys: [][]byte{[]byte("0123456789abcdef")}, Encrypt: true}
+
+	first, err := codec.Encode("session", "secret")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	second, err := codec.Encode("session", "secret")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if first == second {
+		t.Errorf("got the same encoded value twice: %s", first)
+	}
+	if strings.Contains(first, "c2VjcmV0") {
+		t.Errorf("encoded value %s contains the base64 encoded value", first)
+	}
+}
+
+// TestCookieCodecBadKeys tests that encoding fails without keys or
+// with a key which is not a valid AES key.
+func TestCookieCodecBadKeys(t *testing.T) {
+	tests := []struct {
+		name  string
+		codec httphandler.CookieCodec
+	}{
+		{
+			name:  "no keys",
+			codec: httphandler.CookieCodec{},
+		},
+		{
+			name:  "bad encryption key length",
+			codec: httphandler.CookieCodec{Keys: [][]byte{[]byte("short")}, Encrypt: true},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := test.codec.Encode("session", "value"); err == nil {
+				t.Error("got nil error, wanted non-nil")
+			}
+		})
+	}
+}
+
+// TestCookieCodecReadCookie tests that a cookie produced by a
+// CookieCodec can be sent back on a request and read from it.
+func TestCookieCodecReadCookie(t *testing.T) {
+	codec := httphandler.CookieCodec{Keys: [][]byte{[]byte("some signing key")}, MaxAge: time.Hour}
+	cookie, err := codec.Cookie("session", "user=42")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := cookie.MaxAge, 3600; got != want {
+		t.Errorf("got MaxAge %d, wanted %d", got, want)
+	}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(cookie)
+
+	got, err := codec.ReadCookie(r, "session")
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "user=42" {
+		t.Errorf("got value %q, wanted %q", got, "user=42")
+	}
+	if _, err := codec.ReadCookie(r, "missing"); !errors.Is(err, http.ErrNoCookie) {
+		t.Errorf("got error %v, wanted http.ErrNoCookie", err)
+	}
+}
@@ -0,0 +1,62 @@
+package httphandler
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ErrMapping returns the response for an error and true if it knows
+// how to handle that error.
+type ErrMapping func(error) (Response, bool)
+
+// ErrIs returns an ErrMapping which maps any error matching target
+// (according to errors.Is) to resp.
+func ErrIs(target error, resp Response) ErrMapping {
+	return func(err error) (Response, bool) {
+		if !errors.Is(err, target) {
+			return Response{}, false
+		}
+		return resp, true
+	}
+}
+
+// ErrAs returns an ErrMapping which maps any error which can be
+// converted to a T (according to errors.As) to the response returned
+// by fn.
+func ErrAs[T error](fn func(T) Response) ErrMapping {
+	return func(err error) (Response, bool) {
+		var target T
+		if !errors.As(err, &target) {
+			return Response{}, false
+		}
+		return fn(target), true
+	}
+}
+
+// ErrMapper is an ErrPresenter which turns the errors returned from
+// another ErrPresenter into responses. That way handlers can just
+// return sentinel or typed errors instead of each deciding which
+// status code a given error deserves.
+type ErrMapper struct {
+	ErrPresenter ErrPresenter
+	Mappings     []ErrMapping
+}
+
+// ErrPresentHTTP returns the response and error from an ErrPresenter.
+// If the error is non-nil and the response is the zero value then the
+// response comes from the first of Mappings which matches the error.
+// If none match the response stays the zero value so DefaultResp can
+// supply one. The error is always returned so ErrHandler still gets
+// to see it.
+func (e ErrMapper) ErrPresentHTTP(r *http.Request) (Response, error) {
+	resp, err := e.ErrPresenter.ErrPresentHTTP(r)
+	if err == nil || !resp.isZero() {
+		return resp, err
+	}
+	for _, mapping := range e.Mappings {
+		if mapped, ok := mapping(err); ok {
+			return mapped, err
+		}
+	}
+	return resp, err
+}
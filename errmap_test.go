@@ -0,0 +1,97 @@
+package httphandler_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lag13/httphandler"
+)
+
+var errNotFound = errors.New("not found")
+
+type validationErr struct {
+	field string
+}
+
+func (v validationErr) Error() string {
+	return fmt.Sprintf("invalid field %s", v.field)
+}
+
+// TestErrMapper tests that ErrMapper maps errors to the response of
+// the first matching mapping and leaves everything else alone.
+func TestErrMapper(t *testing.T) {
+	tests := []struct {
+		name       string
+		resp       httphandler.Response
+		err        error
+		wantResp   httphandler.Response
+		wantErrMsg string
+	}{
+		{
+			name:       "no error",
+			resp:       httphandler.Response{StatusCode: 200},
+			err:        nil,
+			wantResp:   httphandler.Response{StatusCode: 200},
+			wantErrMsg: "<nil>",
+		},
+		{
+			name:       "sentinel error",
+			resp:       httphandler.Response{},
+			err:        fmt.Errorf("fetching user: %w", errNotFound),
+			wantResp:   httphandler.Response{StatusCode: 404, Body: []byte("not found")},
+			wantErrMsg: "fetching user: not found",
+		},
+		{
+			name:       "typed error",
+			resp:       httphandler.Response{},
+			err:        fmt.Errorf("creating user: %w", validationErr{field: "email"}),
+			wantResp:   httphandler.Response{StatusCode: 400, Body: []byte("bad email")},
+			wantErrMsg: "creating user: invalid field email",
+		},
+		{
+			name:       "unmatched error",
+			resp:       httphandler.Response{},
+			err:        errors.New("db is down"),
+			wantResp:   httphandler.Response{},
+			wantErrMsg: "db is down",
+		},
+		{
+			name:       "a response from the presenter takes precedence",
+			resp:       httphandler.Response{StatusCode: 410},
+			err:        errNotFound,
+			wantResp:   httphandler.Response{StatusCode: 410},
+			wantErrMsg: "not found",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sut := httphandler.ErrMapper{
+				ErrPresenter: httphandler.ErrPresenterFunc(func(*http.Request) (httphandler.Response, error) {
+					return test.resp, test.err
+				}),
+				Mappings: []httphandler.ErrMapping{
+					httphandler.ErrIs(errNotFound, httphandler.Response{StatusCode: 404, Body: []byte("not found")}),
+					httphandler.ErrAs(func(err validationErr) httphandler.Response {
+						return httphandler.Response{StatusCode: 400, Body: []byte("bad " + err.field)}
+					}),
+					httphandler.ErrIs(errNotFound, httphandler.Response{StatusCode: 500}),
+				},
+			}
+
+			gotResp, gotErr := sut.ErrPresentHTTP(httptest.NewRequest(http.MethodGet, "/users/1", nil))
+
+			if got, want := gotResp.StatusCode, test.wantResp.StatusCode; got != want {
+				t.Errorf("got status code %v, wanted %v", got, want)
+			}
+			if got, want := string(gotResp.Body), string(test.wantResp.Body); got != want {
+				t.Errorf("got body: %s, wanted: %s", got, want)
+			}
+			if got, want := fmt.Sprintf("%v", gotErr), test.wantErrMsg; got != want {
+				t.Errorf("got error msg: %s, wanted error msg: %s", got, want)
+			}
+		})
+	}
+}
@@ -1,11 +1,16 @@
 package httphandler_test
 
 import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
 	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"testing/fstest"
 
 	"github.com/lag13/httphandler"
 )
@@ -98,3 +103,272 @@ func ExampleErrHandler() {
 	// status code: 500
 	// body: something went wrong
 }
+
+func ExampleRouter() {
+	router := httphandler.Router{
+		Routes: []httphandler.Route{
+			{
+				Pattern: "/users/{id}",
+				Presenter: httphandler.Dispatcher{
+					MethodToPresenter: map[string]httphandler.Presenter{
+						http.MethodGet: httphandler.PresenterFunc(func(r *http.Request) httphandler.Response {
+							return httphandler.Response{
+								StatusCode: http.StatusOK,
+								Body:       []byte("user " + httphandler.PathParam(r, "id")),
+							}
+						}),
+					},
+				},
+			},
+		},
+		NotFoundPres: httphandler.PresenterFunc(func(r *http.Request) httphandler.Response {
+			return httphandler.Response{
+				StatusCode: http.StatusNotFound,
+				Body:       []byte("no such path"),
+			}
+		}),
+		MethodNotAllowedPres: httphandler.PresenterFunc(func(r *http.Request) httphandler.Response {
+			return httphandler.Response{
+				StatusCode: http.StatusMethodNotAllowed,
+				Body:       []byte("unsupported method"),
+			}
+		}),
+	}
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/users/42", nil),
+		httptest.NewRequest(http.MethodDelete, "/users/42", nil),
+		httptest.NewRequest(http.MethodGet, "/groups/42", nil),
+	} {
+		resp := router.PresentHTTP(req)
+		fmt.Println("status code:", resp.StatusCode)
+		fmt.Printf("body: %s\n", resp.Body)
+	}
+
+	// Output: status code: 200
+	// body: user 42
+	// status code: 405
+	// body: unsupported method
+	// status code: 404
+	// body: no such path
+}
+
+func ExampleJSONHandler() {
+	type addReq struct {
+		A, B int
+	}
+	type addResp struct {
+		Sum int
+	}
+	errHandler := httphandler.ErrHandler{
+		ErrPresenter: httphandler.JSONHandler[addReq, addResp]{
+			Handle: func(ctx context.Context, r *http.Request, in addReq) (addResp, error) {
+				return addResp{Sum: in.A + in.B}, nil
+			},
+		},
+		HandleErr: func(r *http.Request, err error) {
+			fmt.Printf("on %s %s endpoint got error: %v\n", r.Method, r.URL, err)
+		},
+	}
+	resp := errHandler.PresentHTTP(httptest.NewRequest(http.MethodPost, "/add", strings.NewReader(`{"A": 1, "B": 2}`)))
+	fmt.Println("status code:", resp.StatusCode)
+	fmt.Println("content type:", resp.Header.Get("Content-Type"))
+	fmt.Printf("body: %s\n", resp.Body)
+	resp = errHandler.PresentHTTP(httptest.NewRequest(http.MethodPost, "/add", strings.NewReader(`{"A": 1,`)))
+	fmt.Println("status code:", resp.StatusCode)
+	fmt.Printf("body: %s\n", resp.Body)
+
+	// Output: status code: 200
+	// content type: application/json
+	// body: {"Sum":3}
+	// on POST /add endpoint got error: decoding request body: unexpected EOF
+	// status code: 400
+	// body: {"error":"malformed request body"}
+}
+
+func ExampleRecover() {
+	defaultResp := httphandler.DefaultResp{
+		Presenter: httphandler.Recover{
+			Presenter: httphandler.PresenterFunc(func(r *http.Request) httphandler.Response {
+				var m map[string]int
+				m["oops"]++
+				return httphandler.Response{}
+			}),
+			HandleErr: func(r *http.Request, err error) {
+				var panicErr httphandler.PanicError
+				if errors.As(err, &panicErr) {
+					fmt.Printf("recovered on %s %s endpoint: %v\n", r.Method, r.URL, panicErr.Value)
+				}
+			},
+		},
+		DefaultPresenter: httphandler.PresenterFunc(func(r *http.Request) httphandler.Response {
+			return httphandler.Response{
+				StatusCode: http.StatusInternalServerError,
+				Body:       []byte("unexpected error occurred"),
+			}
+		}),
+	}
+	resp := defaultResp.PresentHTTP(httptest.NewRequest(http.MethodGet, "/r", nil))
+	fmt.Println("status code:", resp.StatusCode)
+	fmt.Printf("body: %s\n", resp.Body)
+
+	// Output: recovered on GET /r endpoint: assignment to entry in nil map
+	// status code: 500
+	// body: unexpected error occurred
+}
+
+func ExampleErrMapper() {
+	errNoSuchUser := errors.New("no such user")
+	errHandler := httphandler.ErrHandler{
+		ErrPresenter: httphandler.ErrMapper{
+			ErrPresenter: httphandler.ErrPresenterFunc(func(r *http.Request) (httphandler.Response, error) {
+				return httphandler.Response{}, fmt.Errorf("looking up user: %w", errNoSuchUser)
+			}),
+			Mappings: []httphandler.ErrMapping{
+				httphandler.ErrIs(errNoSuchUser, httphandler.Response{
+					StatusCode: http.StatusNotFound,
+					Body:       []byte("user not found"),
+				}),
+			},
+		},
+		HandleErr: func(r *http.Request, err error) {
+			fmt.Printf("on %s %s endpoint got error: %v\n", r.Method, r.URL, err)
+		},
+	}
+	resp := errHandler.PresentHTTP(httptest.NewRequest(http.MethodGet, "/users/7", nil))
+	fmt.Println("status code:", resp.StatusCode)
+	fmt.Printf("body: %s\n", resp.Body)
+
+	// Output: on GET /users/7 endpoint got error: looking up user: no such user
+	// status code: 404
+	// body: user not found
+}
+
+func ExampleProblem() {
+	dispatcher := httphandler.Dispatcher{
+		MethodToPresenter: map[string]httphandler.Presenter{
+			http.MethodGet: httphandler.ErrHandler{
+				ErrPresenter: httphandler.ErrMapper{
+					ErrPresenter: httphandler.ErrPresenterFunc(func(r *http.Request) (httphandler.Response, error) {
+						return httphandler.Response{}, &httphandler.Problem{
+							Status: http.StatusNotFound,
+							Detail: "there is no widget 7",
+						}
+					}),
+					Mappings: []httphandler.ErrMapping{httphandler.ErrProblem()},
+				},
+				HandleErr: func(r *http.Request, err error) {},
+			},
+		},
+		MethodNotSupportedPres: &httphandler.Problem{Status: http.StatusMethodNotAllowed},
+	}
+	resp := dispatcher.PresentHTTP(httptest.NewRequest(http.MethodGet, "/widgets/7", nil))
+	fmt.Println("status code:", resp.StatusCode)
+	fmt.Println("content type:", resp.Header.Get("Content-Type"))
+	fmt.Printf("body: %s\n", resp.Body)
+	resp = dispatcher.PresentHTTP(httptest.NewRequest(http.MethodPost, "/widgets/7", nil))
+	fmt.Println("status code:", resp.StatusCode)
+	fmt.Println("allow:", resp.Header.Get("Allow"))
+	fmt.Printf("body: %s\n", resp.Body)
+
+	// Output: status code: 404
+	// content type: application/problem+json
+	// body: {"detail":"there is no widget 7","status":404,"title":"Not Found"}
+	// status code: 405
+	// allow: GET, HEAD, OPTIONS
+	// body: {"status":405,"title":"Method Not Allowed"}
+}
+
+func ExampleNegotiator() {
+	negotiator := httphandler.Negotiator{
+		Encoders: []httphandler.Encoder{
+			{MediaType: "application/json", Encode: json.Marshal},
+			{MediaType: "application/xml", Encode: xml.Marshal},
+		},
+		NotAcceptablePres: httphandler.PresenterFunc(func(r *http.Request) httphandler.Response {
+			return httphandler.Response{StatusCode: http.StatusNotAcceptable}
+		}),
+	}
+	type point struct {
+		X, Y int
+	}
+	presenter := httphandler.ErrPresenterFunc(func(r *http.Request) (httphandler.Response, error) {
+		return negotiator.Negotiate(r, http.StatusOK, point{X: 1, Y: 2})
+	})
+	for _, accept := range []string{"application/json", "application/xml;q=0.9, */*;q=0.1", "text/html"} {
+		req := httptest.NewRequest(http.MethodGet, "/point", nil)
+		req.Header.Set("Accept", accept)
+		resp, _ := presenter.ErrPresentHTTP(req)
+		fmt.Println("status code:", resp.StatusCode)
+		fmt.Printf("body: %s\n", resp.Body)
+	}
+
+	// Output: status code: 200
+	// body: {"X":1,"Y":2}
+	// status code: 200
+	// body: <point><X>1</X><Y>2</Y></point>
+	// status code: 406
+	// body:
+}
+
+func ExampleNDJSON() {
+	writer := httphandler.Writer{
+		Presenter: httphandler.PresenterFunc(func(r *http.Request) httphandler.Response {
+			return httphandler.NDJSON(func(yield func(int, error) bool) {
+				for i := 1; i <= 3; i++ {
+					if !yield(i*i, nil) {
+						return
+					}
+				}
+			})
+		}),
+		HandleErr: func(r *http.Request, err error) {
+			log.Printf("error on %s %s endpoint: %v", r.Method, r.URL, err)
+		},
+	}
+	w := httptest.NewRecorder()
+	writer.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/squares", nil))
+	fmt.Println("content type:", w.Header().Get("Content-Type"))
+	fmt.Print(w.Body.String())
+
+	// Output: content type: application/x-ndjson
+	// 1
+	// 4
+	// 9
+}
+
+func ExampleFileServer() {
+	router := httphandler.Router{
+		Routes: []httphandler.Route{
+			{
+				Pattern: "/assets/{file...}",
+				Presenter: httphandler.Dispatcher{
+					MethodToPresenter: map[string]httphandler.Presenter{
+						http.MethodGet: httphandler.Ranges{
+							Presenter: httphandler.Conditional{
+								Presenter: httphandler.ErrHandler{
+									ErrPresenter: httphandler.FileServer{
+										FS:    fstest.MapFS{"hello.txt": {Data: []byte("hello, world")}},
+										Param: "file",
+									},
+									HandleErr: func(r *http.Request, err error) {
+										log.Printf("error on %s %s endpoint: %v", r.Method, r.URL, err)
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	req := httptest.NewRequest(http.MethodGet, "/assets/hello.txt", nil)
+	req.Header.Set("Range", "bytes=0-4")
+	resp := router.PresentHTTP(req)
+	fmt.Println("status code:", resp.StatusCode)
+	fmt.Println("content range:", resp.Header.Get("Content-Range"))
+	fmt.Printf("body: %s\n", resp.Body)
+
+	// Output: status code: 206
+	// content range: bytes 0-4/12
+	// body: hello
+}
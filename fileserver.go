@@ -0,0 +1,219 @@
+package httphandler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// FileServer is an ErrPresenter which serves the files of an fs.FS
+// (such as an embed.FS or os.DirFS). The file comes from the path
+// parameter named Param (so FileServer can be routed to by a pattern
+// like /static/{file...}) or from the request's path if Param is
+// empty. Paths which try to escape the root of the FS are treated as
+// not existing.
+//
+// Responses get a Content-Type based on the file's extension or, if
+// that does not work, its contents. Files with a modification time
+// are streamed with a Last-Modified header and an ETag made from that
+// time and their size. Files without one (such as those in an
+// embed.FS) are read into memory and get an ETag computed from their
+// contents. Wrap FileServer with Conditional and Ranges to support
+// conditional and range requests.
+//
+// A request for a directory is redirected to the same path with a
+// trailing slash which then serves the index.html file within that
+// directory. If there is no such file then the directory's contents
+// are listed if ListDirectories is set and it is treated as not
+// existing otherwise. When a file does not exist FallbackFile is
+// served instead (which makes single page applications work by
+// setting it to "index.html") and if that is empty or also does not
+// exist then NotFoundPres is used. If NotFoundPres is nil then an
+// empty 404 response is returned.
+type FileServer struct {
+	FS              fs.FS
+	Param           string
+	FallbackFile    string
+	ListDirectories bool
+	NotFoundPres    Presenter
+}
+
+// ErrPresentHTTP returns a response with the requested file. A 403
+// response is returned along with the error if the file cannot be
+// opened due to its permissions and the zero Response is returned
+// along with any other error from the FS.
+func (fsrv FileServer) ErrPresentHTTP(r *http.Request) (Response, error) {
+	reqPath := r.URL.Path
+	if fsrv.Param != "" {
+		reqPath = PathParam(r, fsrv.Param)
+	}
+	name, ok := fsName(reqPath)
+	if !ok {
+		return fsrv.notFound(r)
+	}
+	resp, err := fsrv.serve(r, name, reqPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		if fsrv.FallbackFile == "" {
+			return fsrv.notFound(r)
+		}
+		resp, err = fsrv.serve(r, fsrv.FallbackFile, "")
+		if errors.Is(err, fs.ErrNotExist) {
+			return fsrv.notFound(r)
+		}
+	}
+	if errors.Is(err, fs.ErrPermission) {
+		return Response{StatusCode: http.StatusForbidden}, err
+	}
+	return resp, err
+}
+
+// serve returns a response for the named file or directory. reqPath
+// is the path that was requested, if any, which is used to redirect
+// to directories.
+func (fsrv FileServer) serve(r *http.Request, name, reqPath string) (Response, error) {
+	f, err := fsrv.FS.Open(name)
+	if err != nil {
+		return Response{}, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return Response{}, err
+	}
+	if !info.IsDir() {
+		return fileResponse(f, info)
+	}
+	f.Close()
+	if reqPath != "" && !strings.HasSuffix(reqPath, "/") {
+		// The redirect is relative (like net/http's) since an
+		// absolute one to a path like //evil.example/ would send
+		// the client to another host.
+		u := url.URL{Path: path.Base(r.URL.Path) + "/", RawQuery: r.URL.RawQuery}
+		return Response{
+			StatusCode: http.StatusMovedPermanently,
+			Header:     http.Header{"Location": {u.String()}},
+		}, nil
+	}
+	resp, err := fsrv.serve(r, path.Join(name, "index.html"), "")
+	if !errors.Is(err, fs.ErrNotExist) {
+		return resp, err
+	}
+	if !fsrv.ListDirectories {
+		return Response{}, fs.ErrNotExist
+	}
+	return dirListing(fsrv.FS, name)
+}
+
+// notFound returns the response for a file which does not exist.
+func (fsrv FileServer) notFound(r *http.Request) (Response, error) {
+	if fsrv.NotFoundPres == nil {
+		return Response{StatusCode: http.StatusNotFound}, nil
+	}
+	return fsrv.NotFoundPres.PresentHTTP(r), nil
+}
+
+// fsName converts a slash separated request path into the name of a
+// file in an fs.FS. It returns false if the path refers to a parent
+// of the FS's root.
+func fsName(reqPath string) (string, bool) {
+	for _, segment := range strings.Split(reqPath, "/") {
+		if segment == ".." {
+			return "", false
+		}
+	}
+	name := strings.Trim(path.Clean("/"+reqPath), "/")
+	if name == "" {
+		name = "."
+	}
+	return name, fs.ValidPath(name)
+}
+
+// fileResponse returns a response with the contents of an open file.
+// The file is closed once it has been read.
+func fileResponse(f fs.File, info fs.FileInfo) (Response, error) {
+	header := http.Header{}
+	contentType := mime.TypeByExtension(path.Ext(info.Name()))
+	if info.ModTime().IsZero() {
+		defer f.Close()
+		body, err := io.ReadAll(f)
+		if err != nil {
+			return Response{}, err
+		}
+		if contentType == "" {
+			contentType = http.DetectContentType(body)
+		}
+		header.Set("Content-Type", contentType)
+		header.Set("ETag", computeETag(body))
+		return Response{StatusCode: http.StatusOK, Header: header, Body: body}, nil
+	}
+
+	var stream io.Reader = f
+	if contentType == "" {
+		sniff := make([]byte, 512)
+		n, err := io.ReadFull(f, sniff)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			f.Close()
+			return Response{}, err
+		}
+		contentType = http.DetectContentType(sniff[:n])
+		if seeker, ok := f.(io.Seeker); ok {
+			if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+				f.Close()
+				return Response{}, err
+			}
+		} else {
+			stream = sniffedFile{Reader: io.MultiReader(bytes.NewReader(sniff[:n]), f), f: f}
+		}
+	}
+	header.Set("Content-Type", contentType)
+	header.Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+	header.Set("Last-Modified", info.ModTime().UTC().Format(http.TimeFormat))
+	header.Set("ETag", fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size()))
+	return Response{StatusCode: http.StatusOK, Header: header, Stream: stream}, nil
+}
+
+// sniffedFile is a file which could not seek back to its start after
+// its contents were sniffed. Reading it reads the sniffed bytes and
+// then the rest of the file.
+type sniffedFile struct {
+	io.Reader
+	f fs.File
+}
+
+func (s sniffedFile) Close() error {
+	return s.f.Close()
+}
+
+// dirListing returns an HTML page linking to the entries of the named
+// directory.
+func dirListing(fsys fs.FS, name string) (Response, error) {
+	entries, err := fs.ReadDir(fsys, name)
+	if err != nil {
+		return Response{}, err
+	}
+	var buf bytes.Buffer
+	buf.WriteString("<!doctype html>\n<meta name=\"viewport\" content=\"width=device-width\">\n<pre>\n")
+	for _, entry := range entries {
+		entryName := entry.Name()
+		if entry.IsDir() {
+			entryName += "/"
+		}
+		u := url.URL{Path: entryName}
+		fmt.Fprintf(&buf, "<a href=\"%s\">%s</a>\n", html.EscapeString(u.String()), html.EscapeString(entryName))
+	}
+	buf.WriteString("</pre>\n")
+	return Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"text/html; charset=utf-8"}},
+		Body:       buf.Bytes(),
+	}, nil
+}
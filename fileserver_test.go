@@ -0,0 +1,212 @@
+package httphandler_test
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/lag13/httphandler"
+)
+
+// TestFileServer tests that FileServer serves files, index files,
+// directory listings and fallback files and refuses to serve anything
+// outside of its FS.
+func TestFileServer(t *testing.T) {
+	modTime := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"index.html":         {Data: []byte("<h1>home</h1>")},
+		"app.js":             {Data: []byte("console.log(1)")},
+		"notes":              {Data: []byte("just some text"), ModTime: modTime},
+		"docs/guide.txt":     {Data: []byte("a guide")},
+		"docs/api/index.htm": {Data: []byte("not an index")},
+		"evil.example/a.txt": {Data: []byte("a")},
+	}
+	tests := []struct {
+		name           string
+		fileServer     httphandler.FileServer
+		path           string
+		wantStatusCode int
+		wantHeader     http.Header
+		wantBody       string
+	}{
+		{
+			name:           "serves a file",
+			fileServer:     httphandler.FileServer{FS: fsys},
+			path:           "/app.js",
+			wantStatusCode: 200,
+			wantHeader:     http.Header{"Content-Type": {"text/javascript; charset=utf-8"}},
+			wantBody:       "console.log(1)",
+		},
+		{
+			name:           "sniffs the content type and streams files with a modification time",
+			fileServer:     httphandler.FileServer{FS: fsys},
+			path:           "/notes",
+			wantStatusCode: 200,
+			wantHeader: http.Header{
+				"Content-Type":   {"text/plain; charset=utf-8"},
+				"Content-Length": {"14"},
+				"Last-Modified":  {"Sat, 01 Jan 2000 00:00:00 GMT"},
+				"Etag":           {`"d234ccf52430000-e"`},
+			},
+			wantBody: "just some text",
+		},
+		{
+			name:           "serves the index file of the root",
+			fileServer:     httphandler.FileServer{FS: fsys},
+			path:           "/",
+			wantStatusCode: 200,
+			wantBody:       "<h1>home</h1>",
+		},
+		{
+			name:           "redirects to directories with a trailing slash",
+			fileServer:     httphandler.FileServer{FS: fsys},
+			path:           "/docs?x=1",
+			wantStatusCode: 301,
+			wantHeader:     http.Header{"Location": {"docs/?x=1"}},
+		},
+		{
+			name:           "redirects cannot go to another host",
+			fileServer:     httphandler.FileServer{FS: fsys},
+			path:           "//evil.example",
+			wantStatusCode: 301,
+			wantHeader:     http.Header{"Location": {"evil.example/"}},
+		},
+		{
+			name:           "directories without an index file are not found",
+			fileServer:     httphandler.FileServer{FS: fsys},
+			path:           "/docs/",
+			wantStatusCode: 404,
+		},
+		{
+			name:           "lists directories",
+			fileServer:     httphandler.FileServer{FS: fsys, ListDirectories: true},
+			path:           "/docs/",
+			wantStatusCode: 200,
+			wantHeader:     http.Header{"Content-Type": {"text/html; charset=utf-8"}},
+			wantBody:       "<!doctype html>\n<meta name=\"viewport\" content=\"width=device-width\">\n<pre>\n<a href=\"api/\">api/</a>\n<a href=\"guide.txt\">guide.txt</a>\n</pre>\n",
+		},
+		{
+			name:           "missing file",
+			fileServer:     httphandler.FileServer{FS: fsys},
+			path:           "/missing.js",
+			wantStatusCode: 404,
+		},
+		{
+			name: "missing file with a NotFoundPres",
+			fileServer: httphandler.FileServer{
+				FS: fsys,
+				NotFoundPres: httphandler.PresenterFunc(func(*http.Request) httphandler.Response {
+					return httphandler.Response{StatusCode: 404, Body: []byte("nope")}
+				}),
+			},
+			path:           "/missing.js",
+			wantStatusCode: 404,
+			wantBody:       "nope",
+		},
+		{
+			name:           "falls back to another file",
+			fileServer:     httphandler.FileServer{FS: fsys, FallbackFile: "index.html"},
+			path:           "/some/client/route",
+			wantStatusCode: 200,
+			wantBody:       "<h1>home</h1>",
+		},
+		{
+			name:           "missing fallback file",
+			fileServer:     httphandler.FileServer{FS: fsys, FallbackFile: "missing.html"},
+			path:           "/some/client/route",
+			wantStatusCode: 404,
+		},
+		{
+			name:           "cannot escape the root",
+			fileServer:     httphandler.FileServer{FS: fsys, FallbackFile: "index.html"},
+			path:           "/docs/../../secret",
+			wantStatusCode: 404,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.URL.Path, req.URL.RawQuery, _ = strings.Cut(test.path, "?")
+
+			gotResp, err := test.fileServer.ErrPresentHTTP(req)
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got, want := gotResp.StatusCode, test.wantStatusCode; got != want {
+				t.Errorf("got status code %v, wanted %v", got, want)
+			}
+			for name, want := range test.wantHeader {
+				if got := gotResp.Header.Values(name); !reflect.DeepEqual(got, want) {
+					t.Errorf("got %s header %v, wanted %v", name, got, want)
+				}
+			}
+			if got, want := readBody(t, gotResp), test.wantBody; got != want {
+				t.Errorf("got body: %q, wanted: %q", got, want)
+			}
+		})
+	}
+}
+
+// TestFileServerParam tests that FileServer can serve the file named
+// by a path parameter of a Router.
+func TestFileServerParam(t *testing.T) {
+	router := httphandler.Router{
+		Routes: []httphandler.Route{
+			{
+				Pattern: "/static/{file...}",
+				Presenter: httphandler.Dispatcher{
+					MethodToPresenter: map[string]httphandler.Presenter{
+						http.MethodGet: httphandler.ErrHandler{
+							ErrPresenter: httphandler.FileServer{
+								FS:    fstest.MapFS{"css/site.css": {Data: []byte("body{}")}},
+								Param: "file",
+							},
+							HandleErr: func(_ *http.Request, err error) { t.Errorf("unexpected error: %v", err) },
+						},
+					},
+				},
+			},
+		},
+	}
+
+	gotResp := router.PresentHTTP(httptest.NewRequest(http.MethodGet, "/static/css/site.css", nil))
+
+	if got, want := gotResp.StatusCode, 200; got != want {
+		t.Errorf("got status code %v, wanted %v", got, want)
+	}
+	if got, want := gotResp.Header.Get("Content-Type"), "text/css; charset=utf-8"; got != want {
+		t.Errorf("got Content-Type %s, wanted %s", got, want)
+	}
+	if got, want := string(gotResp.Body), "body{}"; got != want {
+		t.Errorf("got body: %s, wanted: %s", got, want)
+	}
+}
+
+// permissionFS is an fs.FS which denies access to every file.
+type permissionFS struct{}
+
+func (permissionFS) Open(name string) (fs.File, error) {
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrPermission}
+}
+
+// TestFileServerPermission tests that files which cannot be opened
+// due to their permissions result in a 403 response and an error.
+func TestFileServerPermission(t *testing.T) {
+	sut := httphandler.FileServer{FS: permissionFS{}}
+
+	gotResp, err := sut.ErrPresentHTTP(httptest.NewRequest(http.MethodGet, "/secret", nil))
+
+	if !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("got error %v, wanted fs.ErrPermission", err)
+	}
+	if got, want := gotResp.StatusCode, 403; got != want {
+		t.Errorf("got status code %v, wanted %v", got, want)
+	}
+}
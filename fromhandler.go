@@ -0,0 +1,206 @@
+package httphandler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"strings"
+)
+
+// FromHandler returns a Presenter which runs an http.Handler against
+// an in-memory http.ResponseWriter and converts what it wrote into a
+// Response. This lets existing handlers live alongside Presenters
+// (inside a Dispatcher or DefaultResp for example) while migrating to
+// this package.
+//
+// What the handler writes is buffered until it returns unless it
+// calls Flush. From then on the Response streams whatever else the
+// handler writes. Trailers are supported in both cases but ones which
+// are only announced with the http.TrailerPrefix once the handler has
+// flushed are dropped since the header has been sent by then.
+// Hijacking is not supported and returns an error wrapping
+// http.ErrNotSupported. A panic in the handler is passed on if it
+// happens before the handler flushes and ends the stream with a
+// PanicError otherwise.
+func FromHandler(h http.Handler) Presenter {
+	return PresenterFunc(func(r *http.Request) Response {
+		ctx, cancel := context.WithCancel(r.Context())
+		w := &handlerWriter{header: http.Header{}, ready: make(chan struct{})}
+		go w.serve(h, r.WithContext(ctx))
+		<-w.ready
+		if w.pw == nil {
+			cancel()
+			if w.panicked {
+				panic(w.panicVal)
+			}
+			return w.response()
+		}
+		header, trailer := splitTrailers(w.sent)
+		for name := range trailer {
+			// The values are filled in once the handler returns.
+			trailer[name] = nil
+		}
+		if len(trailer) == 0 {
+			trailer = nil
+		}
+		return Response{
+			StatusCode: w.statusCode,
+			Header:     header,
+			Stream:     handlerStream{pr: w.pr, w: w, trailer: trailer, cancel: cancel},
+			Trailer:    trailer,
+		}
+	})
+}
+
+// handlerWriter is the http.ResponseWriter given to a handler by
+// FromHandler. Once ready is closed only the handler's goroutine
+// touches header and the pipe.
+type handlerWriter struct {
+	header     http.Header
+	sent       http.Header
+	statusCode int
+	body       bytes.Buffer
+	pr         *io.PipeReader
+	pw         *io.PipeWriter
+	ready      chan struct{}
+	panicked   bool
+	panicVal   interface{}
+}
+
+// serve runs the handler and then either signals that the response is
+// ready or, if the handler flushed, ends the stream.
+func (w *handlerWriter) serve(h http.Handler, r *http.Request) {
+	defer func() {
+		v := recover()
+		if w.pw == nil {
+			w.WriteHeader(http.StatusOK)
+			w.panicked, w.panicVal = v != nil, v
+			close(w.ready)
+			return
+		}
+		if v != nil {
+			w.pw.CloseWithError(PanicError{Value: v, Stack: debug.Stack()})
+			return
+		}
+		w.pw.Close()
+	}()
+	h.ServeHTTP(w, r)
+}
+
+func (w *handlerWriter) Header() http.Header {
+	return w.header
+}
+
+// WriteHeader records the status code and the header as it is at that
+// moment. Informational (1xx) responses are dropped.
+func (w *handlerWriter) WriteHeader(statusCode int) {
+	if w.statusCode != 0 || statusCode < 200 {
+		return
+	}
+	w.statusCode = statusCode
+	w.sent = w.header.Clone()
+}
+
+func (w *handlerWriter) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	if w.pw != nil {
+		return w.pw.Write(p)
+	}
+	return w.body.Write(p)
+}
+
+// Flush switches the response over to being streamed.
+func (w *handlerWriter) Flush() {
+	if w.pw != nil {
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.pr, w.pw = io.Pipe()
+	buffered := w.body.Bytes()
+	close(w.ready)
+	if len(buffered) > 0 {
+		w.pw.Write(buffered)
+	}
+}
+
+func (w *handlerWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, fmt.Errorf("%w: FromHandler cannot hijack connections", http.ErrNotSupported)
+}
+
+// response returns the buffered response of a handler which has
+// returned.
+func (w *handlerWriter) response() Response {
+	header, trailer := splitTrailers(w.sent)
+	for name := range trailer {
+		if values, ok := w.header[name]; ok {
+			trailer[name] = values
+		} else {
+			delete(trailer, name)
+		}
+	}
+	for name, values := range w.header {
+		if strings.HasPrefix(name, http.TrailerPrefix) {
+			trailer[http.CanonicalHeaderKey(strings.TrimPrefix(name, http.TrailerPrefix))] = values
+		}
+	}
+	if len(trailer) == 0 {
+		trailer = nil
+	}
+	return Response{
+		StatusCode: w.statusCode,
+		Header:     header,
+		Body:       w.body.Bytes(),
+		Trailer:    trailer,
+	}
+}
+
+// splitTrailers returns a copy of header without any trailer related
+// fields along with the trailers it announces (without values).
+func splitTrailers(header http.Header) (http.Header, http.Header) {
+	header = cloneHeader(header)
+	trailer := http.Header{}
+	for _, value := range header.Values("Trailer") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				trailer[http.CanonicalHeaderKey(name)] = nil
+			}
+		}
+	}
+	header.Del("Trailer")
+	for name := range header {
+		if strings.HasPrefix(name, http.TrailerPrefix) {
+			delete(header, name)
+		}
+	}
+	return header, trailer
+}
+
+// handlerStream is the stream of a handler which flushed. Once the
+// handler is done the values of its trailers are copied into trailer.
+// Closing it cancels the handler's context and makes its writes fail.
+type handlerStream struct {
+	pr      *io.PipeReader
+	w       *handlerWriter
+	trailer http.Header
+	cancel  context.CancelFunc
+}
+
+func (s handlerStream) Read(p []byte) (int, error) {
+	n, err := s.pr.Read(p)
+	if err == io.EOF {
+		for name := range s.trailer {
+			s.trailer[name] = s.w.header[name]
+		}
+	}
+	return n, err
+}
+
+func (s handlerStream) Close() error {
+	defer s.cancel()
+	return s.pr.Close()
+}
@@ -0,0 +1,205 @@
+package httphandler_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/lag13/httphandler"
+)
+
+// TestFromHandler tests that what an http.Handler writes is converted
+// into the equivalent Response.
+func TestFromHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		handler        http.HandlerFunc
+		wantStatusCode int
+		wantHeader     http.Header
+		wantBody       string
+		wantTrailer    http.Header
+	}{
+		{
+			name: "status, header and body",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/plain")
+				w.WriteHeader(http.StatusCreated)
+				w.Header().Set("Ignored", "sent too late")
+				io.WriteString(w, "hello ")
+				io.WriteString(w, "world")
+			},
+			wantStatusCode: 201,
+			wantHeader:     http.Header{"Content-Type": {"text/plain"}},
+			wantBody:       "hello world",
+		},
+		{
+			name:           "nothing written",
+			handler:        func(w http.ResponseWriter, r *http.Request) {},
+			wantStatusCode: 200,
+			wantHeader:     http.Header{},
+		},
+		{
+			name: "writing implies a 200",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Method", r.Method)
+				io.WriteString(w, "ok")
+				w.WriteHeader(http.StatusTeapot)
+			},
+			wantStatusCode: 200,
+			wantHeader:     http.Header{"X-Method": {"GET"}},
+			wantBody:       "ok",
+		},
+		{
+			name: "informational responses are dropped",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusEarlyHints)
+				w.WriteHeader(http.StatusAccepted)
+			},
+			wantStatusCode: 202,
+			wantHeader:     http.Header{},
+		},
+		{
+			name: "trailers",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Trailer", "Checksum, Unset")
+				io.WriteString(w, "data")
+				w.Header().Set("Checksum", "abc")
+				w.Header().Set(http.TrailerPrefix+"Late", "1")
+			},
+			wantStatusCode: 200,
+			wantHeader:     http.Header{},
+			wantBody:       "data",
+			wantTrailer:    http.Header{"Checksum": {"abc"}, "Late": {"1"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gotResp := httphandler.FromHandler(test.handler).PresentHTTP(httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if got, want := gotResp.StatusCode, test.wantStatusCode; got != want {
+				t.Errorf("got status code %v, wanted %v", got, want)
+			}
+			if got, want := gotResp.Header, test.wantHeader; !reflect.DeepEqual(got, want) {
+				t.Errorf("got header %v, wanted %v", got, want)
+			}
+			if got, want := string(gotResp.Body), test.wantBody; got != want {
+				t.Errorf("got body: %s, wanted: %s", got, want)
+			}
+			if got, want := gotResp.Trailer, test.wantTrailer; !reflect.DeepEqual(got, want) {
+				t.Errorf("got trailer %v, wanted %v", got, want)
+			}
+		})
+	}
+}
+
+// TestFromHandlerFlush tests that a handler which flushes gets its
+// response streamed, including trailers.
+func TestFromHandlerFlush(t *testing.T) {
+	proceed := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", "Checksum")
+		w.WriteHeader(http.StatusAccepted)
+		io.WriteString(w, "first ")
+		w.(http.Flusher).Flush()
+		<-proceed
+		io.WriteString(w, "second")
+		w.Header().Set("Checksum", "abc")
+	})
+
+	gotResp := httphandler.FromHandler(handler).PresentHTTP(httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if gotResp.Stream == nil {
+		t.Fatal("got a buffered response, wanted a stream")
+	}
+	if got, want := gotResp.StatusCode, 202; got != want {
+		t.Errorf("got status code %v, wanted %v", got, want)
+	}
+	close(proceed)
+	w := httptest.NewRecorder()
+	httphandler.Writer{
+		Presenter: httphandler.PresenterFunc(func(*http.Request) httphandler.Response { return gotResp }),
+		HandleErr: func(_ *http.Request, err error) { t.Errorf("unexpected error: %v", err) },
+	}.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	result := w.Result()
+	if got, want := w.Body.String(), "first second"; got != want {
+		t.Errorf("got body: %s, wanted: %s", got, want)
+	}
+	if got, want := result.Trailer, (http.Header{"Checksum": {"abc"}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got trailers %v, wanted %v", got, want)
+	}
+}
+
+// TestFromHandlerCloseCancels tests that closing the stream of a
+// handler which flushed cancels its context.
+func TestFromHandlerCloseCancels(t *testing.T) {
+	cancelled := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+		close(cancelled)
+	})
+
+	gotResp := httphandler.FromHandler(handler).PresentHTTP(httptest.NewRequest(http.MethodGet, "/", nil))
+	gotResp.Stream.(io.Closer).Close()
+
+	<-cancelled
+}
+
+// TestFromHandlerHijack tests that handlers cannot hijack the
+// connection.
+func TestFromHandlerHijack(t *testing.T) {
+	var gotErr error
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _, gotErr = http.NewResponseController(w).Hijack()
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	gotResp := httphandler.FromHandler(handler).PresentHTTP(httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if !errors.Is(gotErr, http.ErrNotSupported) {
+		t.Errorf("got error %v, wanted http.ErrNotSupported", gotErr)
+	}
+	if got, want := gotResp.StatusCode, 500; got != want {
+		t.Errorf("got status code %v, wanted %v", got, want)
+	}
+}
+
+// TestFromHandlerPanics tests that a panic in a handler is passed on
+// if it happens before flushing and ends the stream otherwise.
+func TestFromHandlerPanics(t *testing.T) {
+	t.Run("before flushing", func(t *testing.T) {
+		defer func() {
+			if got, want := recover(), "boom"; got != want {
+				t.Errorf("got panic %v, wanted %v", got, want)
+			}
+		}()
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic("boom")
+		})
+
+		httphandler.FromHandler(handler).PresentHTTP(httptest.NewRequest(http.MethodGet, "/", nil))
+
+		t.Error("wanted a panic")
+	})
+	t.Run("after flushing", func(t *testing.T) {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, "partial")
+			w.(http.Flusher).Flush()
+			panic("boom")
+		})
+
+		gotResp := httphandler.FromHandler(handler).PresentHTTP(httptest.NewRequest(http.MethodGet, "/", nil))
+		body, err := io.ReadAll(gotResp.Stream)
+
+		var panicErr httphandler.PanicError
+		if !errors.As(err, &panicErr) || panicErr.Value != "boom" {
+			t.Errorf("got error %v, wanted a PanicError", err)
+		}
+		if got, want := string(body), "partial"; got != want {
+			t.Errorf("got body: %s, wanted: %s", got, want)
+		}
+	})
+}
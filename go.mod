@@ -0,0 +1,3 @@
+module github.com/lag13/httphandler
+
+go 1.23
@@ -106,14 +106,50 @@ concrete understanding.
 package httphandler
 
 import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 )
 
-// Response gets written in response to a request.
+// Response gets written in response to a request. Body holds a body
+// which is already in memory. Stream can be set instead (or as well)
+// for bodies which are too large to buffer; it gets written after
+// Body and is closed afterwards if it implements io.Closer.
+//
+// Hijack is for responses which switch protocols (like a WebSocket
+// upgrade). If it is set then the connection is taken over once the
+// status code and header have been written and Hijack is handed the
+// connection instead of Body and Stream being written. Hijack is then
+// responsible for closing the connection.
+//
+// Trailer holds trailers which are sent after the body. Its keys are
+// announced in the Trailer header before the body is written but its
+// values are only read once the body has been written so a Stream
+// can fill them in (with a checksum of itself for example) as it
+// reaches its end.
+//
+// Cookies are written as Set-Cookie headers. Invalid cookies are
+// dropped just like with http.SetCookie.
 type Response struct {
 	StatusCode int
 	Header     http.Header
 	Body       []byte
+	Stream     io.Reader
+	Hijack     func(net.Conn, *bufio.ReadWriter)
+	Trailer    http.Header
+	Cookies    []*http.Cookie
+}
+
+// isZero reports whether resp is the zero value.
+func (resp Response) isZero() bool {
+	return resp.StatusCode == 0 && resp.Header == nil && resp.Body == nil && resp.Stream == nil &&
+		resp.Hijack == nil && resp.Trailer == nil && resp.Cookies == nil
 }
 
 // Presenter will "present" (i.e show/return) the response that will
@@ -124,22 +160,37 @@ type Presenter interface {
 }
 
 // Writer writes the response returned from a Presenter and calls
-// HandleErr if the error returned from Write() is non-nil.
+// HandleErr if an error occurs while writing the body.
 type Writer struct {
 	Presenter Presenter
 	HandleErr func(*http.Request, error)
 }
 
 // ServeHTTP writes the response received from a Presenter and passes
-// the error from Write() into HandleErr. If HandleErr is not
-// specified then the error from Write() is ignored.
+// any error from writing the body into HandleErr. If HandleErr is not
+// specified then that error is ignored. If the error came from
+// copying Stream then the response is aborted afterwards (by
+// panicking with http.ErrAbortHandler) so the client can tell that
+// the body is incomplete rather than seeing it end normally. The body
+// is not written for HEAD requests. See Response for what happens if
+// Hijack is set.
 func (h Writer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	resp := h.Presenter.PresentHTTP(r)
+	if resp.Hijack != nil {
+		h.hijack(w, r, resp)
+		return
+	}
 	for header, values := range resp.Header {
 		for _, value := range values {
 			w.Header().Add(header, value)
 		}
 	}
+	for trailer := range resp.Trailer {
+		w.Header().Add("Trailer", trailer)
+	}
+	for _, cookie := range resp.Cookies {
+		http.SetCookie(w, cookie)
+	}
 	// If http.ResponseWriter.Write() is called before
 	// WriteHeader() then a 200 status code is automatically
 	// written. I stay consistent with that behavior by having
@@ -147,10 +198,119 @@ func (h Writer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if resp.StatusCode == 0 {
 		resp.StatusCode = 200
 	}
-	w.WriteHeader(resp.StatusCode)
-	if _, err := w.Write(resp.Body); err != nil && h.HandleErr != nil {
+	var err error
+	var streamFailed bool
+	if r.Method == http.MethodHead {
+		// The body of a response to a HEAD request is never sent
+		// but the client should still learn how long it would
+		// have been.
+		if resp.Stream == nil && bodyAllowed(resp.StatusCode) && w.Header().Get("Content-Length") == "" {
+			w.Header().Set("Content-Length", strconv.Itoa(len(resp.Body)))
+		}
+		w.WriteHeader(resp.StatusCode)
+		err = closeStream(resp)
+	} else {
+		w.WriteHeader(resp.StatusCode)
+		streamFailed, err = writeBody(w, resp)
+		for trailer, values := range resp.Trailer {
+			w.Header()[http.CanonicalHeaderKey(trailer)] = values
+		}
+	}
+	if err != nil && h.HandleErr != nil {
 		h.HandleErr(r, err)
 	}
+	if streamFailed {
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// hijack takes over the connection, writes the status line and header
+// itself, and passes the connection on to resp.Hijack. If the
+// connection cannot be taken over (for example because it is HTTP/2)
+// then a 500 is written instead.
+func (h Writer) hijack(w http.ResponseWriter, r *http.Request, resp Response) {
+	conn, brw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		if h.HandleErr != nil {
+			h.HandleErr(r, err)
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if resp.StatusCode == 0 {
+		resp.StatusCode = 200
+	}
+	header := cloneHeader(resp.Header)
+	for _, cookie := range resp.Cookies {
+		if v := cookie.String(); v != "" {
+			header.Add("Set-Cookie", v)
+		}
+	}
+	fmt.Fprintf(brw, "HTTP/1.1 %03d %s\r\n", resp.StatusCode, http.StatusText(resp.StatusCode))
+	header.Write(brw)
+	brw.WriteString("\r\n")
+	if err := brw.Flush(); err != nil {
+		conn.Close()
+		if h.HandleErr != nil {
+			h.HandleErr(r, err)
+		}
+		return
+	}
+	resp.Hijack(conn, brw)
+}
+
+// bodyAllowed reports whether a response with the given status code
+// may have a body.
+func bodyAllowed(statusCode int) bool {
+	return statusCode >= 200 && statusCode != http.StatusNoContent && statusCode != http.StatusNotModified
+}
+
+// writeBody writes Body followed by Stream. Stream gets flushed to
+// the client as it is copied (if w supports it) so large bodies do
+// not sit in a buffer, and it is always closed if it is an io.Closer.
+// It also reports whether copying Stream failed partway.
+func writeBody(w http.ResponseWriter, resp Response) (bool, error) {
+	_, err := w.Write(resp.Body)
+	if resp.Stream == nil {
+		return false, err
+	}
+	streamFailed := false
+	if err == nil {
+		// Flush what has been written so far since the stream
+		// could take a while to produce anything.
+		flusher, _ := w.(http.Flusher)
+		if flusher != nil {
+			flusher.Flush()
+		}
+		_, err = io.Copy(flushWriter{w: w, flusher: flusher}, resp.Stream)
+		streamFailed = err != nil
+	}
+	if cerr := closeStream(resp); err == nil {
+		err = cerr
+	}
+	return streamFailed, err
+}
+
+// closeStream closes Stream if it is an io.Closer.
+func closeStream(resp Response) error {
+	if c, ok := resp.Stream.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// flushWriter flushes after every write.
+type flushWriter struct {
+	w       io.Writer
+	flusher http.Flusher
+}
+
+func (f flushWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	if f.flusher != nil {
+		f.flusher.Flush()
+	}
+	return n, err
 }
 
 // DefaultResp is a Presenter which produces a response or a default
@@ -167,14 +327,16 @@ type DefaultResp struct {
 // different Presenter.
 func (d DefaultResp) PresentHTTP(r *http.Request) Response {
 	resp := d.Presenter.PresentHTTP(r)
-	if resp.StatusCode == 0 && resp.Header == nil && resp.Body == nil {
+	if resp.isZero() {
 		return d.DefaultPresenter.PresentHTTP(r)
 	}
 	return resp
 }
 
 // Dispatcher is a Presenter which dispatches to another Presenter
-// based on the http method.
+// based on the http method. If MethodNotSupportedPres is nil then the
+// MethodNotAllowedPres of the Router which routed the request is used
+// instead.
 type Dispatcher struct {
 	MethodToPresenter      map[string]Presenter
 	MethodNotSupportedPres Presenter
@@ -182,13 +344,75 @@ type Dispatcher struct {
 
 // PresentHTTP dispatches to another presenter based off the request's
 // http method. If no matching presenter can be found a default
-// response is returned.
+// response is returned. That default response gets an Allow header
+// listing the supported methods if it is a 405 and the list can also
+// be retrieved with AllowedMethods. OPTIONS requests are answered
+// automatically unless a presenter is registered for them and HEAD
+// requests fall back to the GET presenter.
 func (d Dispatcher) PresentHTTP(r *http.Request) Response {
-	p, ok := d.MethodToPresenter[r.Method]
-	if !ok {
-		return d.MethodNotSupportedPres.PresentHTTP(r)
+	if p, ok := d.MethodToPresenter[r.Method]; ok {
+		return p.PresentHTTP(r)
+	}
+	if p, ok := d.MethodToPresenter[http.MethodGet]; ok && r.Method == http.MethodHead {
+		return p.PresentHTTP(r)
+	}
+	allowed := d.Methods()
+	if r.Method == http.MethodOptions {
+		return Response{
+			StatusCode: http.StatusNoContent,
+			Header:     http.Header{"Allow": {strings.Join(allowed, ", ")}},
+		}
+	}
+	notSupportedPres := d.MethodNotSupportedPres
+	if notSupportedPres == nil {
+		notSupportedPres, _ = r.Context().Value(methodNotAllowedKey{}).(Presenter)
+	}
+	r = r.WithContext(context.WithValue(r.Context(), allowedMethodsKey{}, allowed))
+	resp := notSupportedPres.PresentHTTP(r)
+	if resp.StatusCode == http.StatusMethodNotAllowed && resp.Header.Get("Allow") == "" {
+		resp.Header = cloneHeader(resp.Header)
+		resp.Header.Set("Allow", strings.Join(allowed, ", "))
+	}
+	return resp
+}
+
+// Methods returns the sorted list of http methods which the
+// Dispatcher supports.
+func (d Dispatcher) Methods() []string {
+	methods := []string{}
+	for method := range d.MethodToPresenter {
+		methods = append(methods, method)
+	}
+	if _, ok := d.MethodToPresenter[http.MethodOptions]; !ok {
+		methods = append(methods, http.MethodOptions)
+	}
+	_, hasGet := d.MethodToPresenter[http.MethodGet]
+	if _, hasHead := d.MethodToPresenter[http.MethodHead]; hasGet && !hasHead {
+		methods = append(methods, http.MethodHead)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// allowedMethodsKey is the context key under which a Dispatcher
+// stores its supported methods.
+type allowedMethodsKey struct{}
+
+// AllowedMethods returns the methods supported by the Dispatcher
+// which handed this request to its MethodNotSupportedPres. It is nil
+// for any other request.
+func AllowedMethods(r *http.Request) []string {
+	methods, _ := r.Context().Value(allowedMethodsKey{}).([]string)
+	return methods
+}
+
+// cloneHeader returns a deep copy of h which is never nil.
+func cloneHeader(h http.Header) http.Header {
+	clone := make(http.Header, len(h))
+	for key, values := range h {
+		clone[key] = append([]string(nil), values...)
 	}
-	return p.PresentHTTP(r)
+	return clone
 }
 
 // PresenterFunc allows the use of ordinary functions as Presenter's.
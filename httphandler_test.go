@@ -3,9 +3,13 @@ package httphandler_test
 import (
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/lag13/httphandler"
@@ -150,6 +154,238 @@ func TestWriterFails(t *testing.T) {
 	sut.ServeHTTP(errResponseWriter{}, req)
 }
 
+// closeRecorder is an io.Reader which records whether it was closed.
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
+
+// errReader is an io.Reader which always fails.
+type errReader struct{}
+
+func (e errReader) Read([]byte) (int, error) {
+	return 0, errors.New("non-nil error occurred when reading")
+}
+
+// TestWriterStreams tests that Writer copies a streaming body after
+// the buffered body, flushes it, and closes it once it is done.
+func TestWriterStreams(t *testing.T) {
+	stream := &closeRecorder{Reader: strings.NewReader(" and a streamed body")}
+	sut := httphandler.Writer{
+		Presenter: httphandler.PresenterFunc(func(*http.Request) httphandler.Response {
+			return httphandler.Response{
+				StatusCode: 201,
+				Body:       []byte("a buffered body"),
+				Stream:     stream,
+			}
+		}),
+	}
+	w := httptest.NewRecorder()
+
+	sut.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/stream", nil))
+
+	if got, want := w.Code, 201; got != want {
+		t.Errorf("got status code %v, wanted %v", got, want)
+	}
+	if got, want := w.Body.String(), "a buffered body and a streamed body"; got != want {
+		t.Errorf("got body: %s, wanted: %s", got, want)
+	}
+	if !w.Flushed {
+		t.Errorf("the streamed body was not flushed")
+	}
+	if !stream.closed {
+		t.Errorf("the stream was not closed")
+	}
+}
+
+// TestWriterStreamFails tests that an error while copying a streaming
+// body gets passed to HandleErr.
+func TestWriterStreamFails(t *testing.T) {
+	fnErrHandler := fnToHandleErr{}
+	sut := httphandler.Writer{
+		Presenter: httphandler.PresenterFunc(func(*http.Request) httphandler.Response {
+			return httphandler.Response{Stream: errReader{}}
+		}),
+		HandleErr: fnErrHandler.handleError,
+	}
+
+	aborted := serveAborts(sut, httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/stream", nil))
+
+	if got, want := fmt.Sprintf("%v", fnErrHandler.gotErr), "non-nil error occurred when reading"; got != want {
+		t.Errorf("got error msg: %s, wanted error msg: %s", got, want)
+	}
+	if !aborted {
+		t.Error("the response was not aborted")
+	}
+}
+
+// serveAborts calls h.ServeHTTP and reports whether it aborted the
+// response by panicking with http.ErrAbortHandler.
+func serveAborts(h http.Handler, w http.ResponseWriter, r *http.Request) (aborted bool) {
+	defer func() {
+		if v := recover(); v != nil {
+			if v != http.ErrAbortHandler {
+				panic(v)
+			}
+			aborted = true
+		}
+	}()
+	h.ServeHTTP(w, r)
+	return false
+}
+
+// TestWriterHead tests that Writer does not write the body of a
+// response to a HEAD request but still reports its length.
+func TestWriterHead(t *testing.T) {
+	tests := []struct {
+		name              string
+		resp              httphandler.Response
+		wantStatusCode    int
+		wantContentLength string
+		wantStreamClosed  bool
+	}{
+		{
+			name:              "content length comes from the body",
+			resp:              httphandler.Response{Body: []byte("hello world!")},
+			wantStatusCode:    200,
+			wantContentLength: "12",
+		},
+		{
+			name: "an explicit content length is kept",
+			resp: httphandler.Response{
+				StatusCode: 200,
+				Header:     http.Header{"Content-Length": {"1000"}},
+				Stream:     &closeRecorder{Reader: strings.NewReader("unread")},
+			},
+			wantStatusCode:    200,
+			wantContentLength: "1000",
+			wantStreamClosed:  true,
+		},
+		{
+			name: "the length of a stream is unknown",
+			resp: httphandler.Response{
+				StatusCode: 200,
+				Stream:     &closeRecorder{Reader: strings.NewReader("unread")},
+			},
+			wantStatusCode:    200,
+			wantContentLength: "",
+			wantStreamClosed:  true,
+		},
+		{
+			name:              "no content length for a 204",
+			resp:              httphandler.Response{StatusCode: http.StatusNoContent},
+			wantStatusCode:    http.StatusNoContent,
+			wantContentLength: "",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			sut := httphandler.Writer{
+				Presenter: httphandler.PresenterFunc(func(*http.Request) httphandler.Response {
+					return test.resp
+				}),
+			}
+
+			sut.ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/head", nil))
+
+			if got, want := w.Code, test.wantStatusCode; got != want {
+				t.Errorf("got status code %v, wanted %v", got, want)
+			}
+			if got, want := w.Header().Get("Content-Length"), test.wantContentLength; got != want {
+				t.Errorf("got content length %q, wanted %q", got, want)
+			}
+			if got := w.Body.String(); got != "" {
+				t.Errorf("got body: %s, wanted no body", got)
+			}
+			if stream, ok := test.resp.Stream.(*closeRecorder); ok && stream.closed != test.wantStreamClosed {
+				t.Errorf("stream being closed was %v", stream.closed)
+			}
+		})
+	}
+}
+
+// trailerStream is a stream which records its length in a trailer
+// once it has been read to the end.
+type trailerStream struct {
+	io.Reader
+	trailer http.Header
+	n       int
+}
+
+func (s *trailerStream) Read(p []byte) (int, error) {
+	n, err := s.Reader.Read(p)
+	s.n += n
+	if err == io.EOF {
+		s.trailer.Set("Body-Length", strconv.Itoa(s.n))
+	}
+	return n, err
+}
+
+// TestWriterTrailers tests that trailers are announced before the body
+// and written after it, including values only known at the end.
+func TestWriterTrailers(t *testing.T) {
+	trailer := http.Header{"Body-Length": nil, "Grpc-Status": {"0"}}
+	sut := httphandler.Writer{
+		Presenter: httphandler.PresenterFunc(func(*http.Request) httphandler.Response {
+			return httphandler.Response{
+				StatusCode: 200,
+				Stream:     &trailerStream{Reader: strings.NewReader("some streamed data"), trailer: trailer},
+				Trailer:    trailer,
+			}
+		}),
+	}
+	w := httptest.NewRecorder()
+
+	sut.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/trailers", nil))
+
+	result := w.Result()
+	if got, want := result.Header.Values("Trailer"), []string{"Body-Length", "Grpc-Status"}; !reflect.DeepEqual(sorted(got), want) {
+		t.Errorf("got trailer header %v, wanted %v", got, want)
+	}
+	wantTrailer := http.Header{"Body-Length": {"18"}, "Grpc-Status": {"0"}}
+	if got := result.Trailer; !reflect.DeepEqual(got, wantTrailer) {
+		t.Errorf("got trailers %v, wanted %v", got, wantTrailer)
+	}
+}
+
+// TestWriterCookies tests that the cookies of a response are written
+// as Set-Cookie headers alongside any which are already in its header
+// and that invalid cookies are dropped.
+func TestWriterCookies(t *testing.T) {
+	sut := httphandler.Writer{
+		Presenter: httphandler.PresenterFunc(func(*http.Request) httphandler.Response {
+			return httphandler.Response{
+				StatusCode: 200,
+				Header:     http.Header{"Set-Cookie": {"a=1"}},
+				Cookies: []*http.Cookie{
+					{Name: "b", Value: "2", Path: "/", HttpOnly: true},
+					{Name: "bad name", Value: "3"},
+				},
+			}
+		}),
+	}
+	w := httptest.NewRecorder()
+
+	sut.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/cookies", nil))
+
+	if got, want := w.Result().Header.Values("Set-Cookie"), []string{"a=1", "b=2; Path=/; HttpOnly"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got Set-Cookie headers %q, wanted %q", got, want)
+	}
+}
+
+// sorted returns a sorted copy of values.
+func sorted(values []string) []string {
+	values = append([]string{}, values...)
+	sort.Strings(values)
+	return values
+}
+
 // TestDefaultResp tests that DefaultResp will produce the expected
 // response from a presenter or a default response if that presenter
 // returns a response with a status code of 0.
@@ -197,6 +433,26 @@ func TestDefaultResp(t *testing.T) {
 				Body:       []byte("got request with method GET on path /whats-up-doc"),
 			},
 		},
+		{
+			name: "presenter returns just a stream",
+			presenter: httphandler.PresenterFunc(func(r *http.Request) httphandler.Response {
+				return httphandler.Response{
+					Stream: strings.NewReader("streamed"),
+				}
+			}),
+			defaultPresenter: httphandler.PresenterFunc(func(*http.Request) httphandler.Response {
+				return httphandler.Response{
+					StatusCode: 500,
+					Body:       []byte("default response!"),
+				}
+			}),
+			request: httptest.NewRequest(http.MethodGet, "/whats-up-doc", nil),
+			wantResp: httphandler.Response{
+				StatusCode: 0,
+				Header:     nil,
+				Body:       nil,
+			},
+		},
 		{
 			name: "default response is returned",
 			presenter: httphandler.PresenterFunc(func(r *http.Request) httphandler.Response {
@@ -298,10 +554,103 @@ func TestDispatcher(t *testing.T) {
 			request: httptest.NewRequest(http.MethodPost, "/hello-there-buddy", nil),
 			wantResp: httphandler.Response{
 				StatusCode: http.StatusMethodNotAllowed,
-				Header:     nil,
+				Header:     http.Header{"Allow": {"OPTIONS"}},
 				Body:       []byte("the method POST is not allowed"),
 			},
 		},
+		{
+			name: "allow header lists the supported methods",
+			methodToPresenter: map[string]httphandler.Presenter{
+				http.MethodPut:    nil,
+				http.MethodGet:    nil,
+				http.MethodDelete: nil,
+			},
+			notFoundFn: func(r *http.Request) httphandler.Response {
+				return httphandler.Response{
+					StatusCode: http.StatusMethodNotAllowed,
+					Header:     http.Header{"Content-Type": {"text/plain"}},
+					Body:       []byte(fmt.Sprintf("use one of %v", httphandler.AllowedMethods(r))),
+				}
+			},
+			request: httptest.NewRequest(http.MethodPost, "/hello-there-buddy", nil),
+			wantResp: httphandler.Response{
+				StatusCode: http.StatusMethodNotAllowed,
+				Header: http.Header{
+					"Allow":        {"DELETE, GET, HEAD, OPTIONS, PUT"},
+					"Content-Type": {"text/plain"},
+				},
+				Body: []byte("use one of [DELETE GET HEAD OPTIONS PUT]"),
+			},
+		},
+		{
+			name:              "allow header is only added to 405 responses",
+			methodToPresenter: map[string]httphandler.Presenter{http.MethodGet: nil},
+			notFoundFn: func(r *http.Request) httphandler.Response {
+				return httphandler.Response{StatusCode: http.StatusNotFound}
+			},
+			request: httptest.NewRequest(http.MethodPost, "/hello-there-buddy", nil),
+			wantResp: httphandler.Response{
+				StatusCode: http.StatusNotFound,
+				Header:     nil,
+				Body:       nil,
+			},
+		},
+		{
+			name:              "OPTIONS is answered automatically",
+			methodToPresenter: map[string]httphandler.Presenter{http.MethodPost: nil, http.MethodGet: nil},
+			notFoundFn:        nil,
+			request:           httptest.NewRequest(http.MethodOptions, "/hello-there-buddy", nil),
+			wantResp: httphandler.Response{
+				StatusCode: http.StatusNoContent,
+				Header:     http.Header{"Allow": {"GET, HEAD, OPTIONS, POST"}},
+				Body:       nil,
+			},
+		},
+		{
+			name: "HEAD falls back to the GET presenter",
+			methodToPresenter: map[string]httphandler.Presenter{
+				http.MethodGet: httphandler.PresenterFunc(func(r *http.Request) httphandler.Response {
+					return httphandler.Response{StatusCode: 200, Body: []byte("got " + r.Method)}
+				}),
+			},
+			notFoundFn: nil,
+			request:    httptest.NewRequest(http.MethodHead, "/hello-there-buddy", nil),
+			wantResp: httphandler.Response{
+				StatusCode: 200,
+				Header:     nil,
+				Body:       []byte("got HEAD"),
+			},
+		},
+		{
+			name: "HEAD is not supported without a GET presenter",
+			methodToPresenter: map[string]httphandler.Presenter{
+				http.MethodPost: nil,
+			},
+			notFoundFn: func(r *http.Request) httphandler.Response {
+				return httphandler.Response{StatusCode: http.StatusMethodNotAllowed}
+			},
+			request: httptest.NewRequest(http.MethodHead, "/hello-there-buddy", nil),
+			wantResp: httphandler.Response{
+				StatusCode: http.StatusMethodNotAllowed,
+				Header:     http.Header{"Allow": {"OPTIONS, POST"}},
+				Body:       nil,
+			},
+		},
+		{
+			name: "a registered OPTIONS presenter takes precedence",
+			methodToPresenter: map[string]httphandler.Presenter{
+				http.MethodOptions: httphandler.PresenterFunc(func(r *http.Request) httphandler.Response {
+					return httphandler.Response{StatusCode: 200, Body: []byte("custom options")}
+				}),
+			},
+			notFoundFn: nil,
+			request:    httptest.NewRequest(http.MethodOptions, "/hello-there-buddy", nil),
+			wantResp: httphandler.Response{
+				StatusCode: 200,
+				Header:     nil,
+				Body:       []byte("custom options"),
+			},
+		},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
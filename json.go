@@ -0,0 +1,79 @@
+package httphandler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// JSONHandler is an ErrPresenter which takes care of the JSON
+// decoding and encoding that surrounds most API handlers. It decodes
+// the request body into an In, passes that to Handle, and encodes the
+// Out which Handle returns as the response body.
+type JSONHandler[In, Out any] struct {
+	Handle func(context.Context, *http.Request, In) (Out, error)
+	// StatusCode is the status code of a successful response. It
+	// defaults to 200.
+	StatusCode int
+}
+
+// ErrPresentHTTP decodes the request body, calls Handle, and encodes
+// the result. An empty request body decodes to the zero value of In.
+// A malformed request body (including one with anything but
+// whitespace after the JSON value) produces a 400 response along with
+// the decoding error and an error from Handle produces the zero
+// Response along with that error (so DefaultResp can supply the
+// response).
+func (j JSONHandler[In, Out]) ErrPresentHTTP(r *http.Request) (Response, error) {
+	var in In
+	if err := decodeJSONBody(r, &in); err != nil {
+		body, _ := json.Marshal(map[string]string{"error": "malformed request body"})
+		return Response{
+			StatusCode: http.StatusBadRequest,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       body,
+		}, fmt.Errorf("decoding request body: %w", err)
+	}
+	out, err := j.Handle(r.Context(), r, in)
+	if err != nil {
+		return Response{}, err
+	}
+	body, err := json.Marshal(out)
+	if err != nil {
+		return Response{}, fmt.Errorf("encoding response body: %w", err)
+	}
+	statusCode := j.StatusCode
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+	return Response{
+		StatusCode: statusCode,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       body,
+	}, nil
+}
+
+// decodeJSONBody decodes the single JSON value in the request body
+// into v. An empty body leaves v alone.
+func decodeJSONBody(r *http.Request, v interface{}) error {
+	if r.Body == nil {
+		return nil
+	}
+	dec := json.NewDecoder(r.Body)
+	if err := dec.Decode(v); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		return err
+	}
+	if err := dec.Decode(&json.RawMessage{}); !errors.Is(err, io.EOF) {
+		if err == nil {
+			err = errors.New("more than one JSON value")
+		}
+		return err
+	}
+	return nil
+}
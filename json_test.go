@@ -0,0 +1,137 @@
+package httphandler_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/lag13/httphandler"
+)
+
+type greetReq struct {
+	Name string `json:"name"`
+}
+
+type greetResp struct {
+	Greeting string `json:"greeting"`
+}
+
+// TestJSONHandler tests that JSONHandler decodes the request, encodes
+// the response, and reports errors.
+func TestJSONHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		handle     func(context.Context, *http.Request, greetReq) (greetResp, error)
+		request    *http.Request
+		wantResp   httphandler.Response
+		wantErrMsg string
+	}{
+		{
+			name: "decodes the request and encodes the response",
+			handle: func(ctx context.Context, r *http.Request, in greetReq) (greetResp, error) {
+				return greetResp{Greeting: "hello " + in.Name}, nil
+			},
+			request: httptest.NewRequest(http.MethodPost, "/greet", strings.NewReader("{\"name\": \"bob\"}\n")),
+			wantResp: httphandler.Response{
+				StatusCode: 200,
+				Header:     http.Header{"Content-Type": {"application/json"}},
+				Body:       []byte(`{"greeting":"hello bob"}`),
+			},
+			wantErrMsg: "<nil>",
+		},
+		{
+			name:       "custom status code and an empty body",
+			statusCode: http.StatusCreated,
+			handle: func(ctx context.Context, r *http.Request, in greetReq) (greetResp, error) {
+				return greetResp{Greeting: "hello" + in.Name}, nil
+			},
+			request: httptest.NewRequest(http.MethodPost, "/greet", nil),
+			wantResp: httphandler.Response{
+				StatusCode: http.StatusCreated,
+				Header:     http.Header{"Content-Type": {"application/json"}},
+				Body:       []byte(`{"greeting":"hello"}`),
+			},
+			wantErrMsg: "<nil>",
+		},
+		{
+			name: "malformed request body",
+			handle: func(ctx context.Context, r *http.Request, in greetReq) (greetResp, error) {
+				t.Errorf("Handle should not have been called")
+				return greetResp{}, nil
+			},
+			request: httptest.NewRequest(http.MethodPost, "/greet", strings.NewReader(`{"name": `)),
+			wantResp: httphandler.Response{
+				StatusCode: http.StatusBadRequest,
+				Header:     http.Header{"Content-Type": {"application/json"}},
+				Body:       []byte(`{"error":"malformed request body"}`),
+			},
+			wantErrMsg: "decoding request body: unexpected EOF",
+		},
+		{
+			name: "trailing data after the request body",
+			handle: func(ctx context.Context, r *http.Request, in greetReq) (greetResp, error) {
+				t.Errorf("Handle should not have been called")
+				return greetResp{}, nil
+			},
+			request: httptest.NewRequest(http.MethodPost, "/greet", strings.NewReader(`{"name": "bob"} {"name": "eve"}`)),
+			wantResp: httphandler.Response{
+				StatusCode: http.StatusBadRequest,
+				Header:     http.Header{"Content-Type": {"application/json"}},
+				Body:       []byte(`{"error":"malformed request body"}`),
+			},
+			wantErrMsg: "decoding request body: more than one JSON value",
+		},
+		{
+			name: "garbage after the request body",
+			handle: func(ctx context.Context, r *http.Request, in greetReq) (greetResp, error) {
+				t.Errorf("Handle should not have been called")
+				return greetResp{}, nil
+			},
+			request: httptest.NewRequest(http.MethodPost, "/greet", strings.NewReader(`{"name": "bob"} garbage`)),
+			wantResp: httphandler.Response{
+				StatusCode: http.StatusBadRequest,
+				Header:     http.Header{"Content-Type": {"application/json"}},
+				Body:       []byte(`{"error":"malformed request body"}`),
+			},
+			wantErrMsg: "decoding request body: invalid character 'g' looking for beginning of value",
+		},
+		{
+			name: "Handle returns an error",
+			handle: func(ctx context.Context, r *http.Request, in greetReq) (greetResp, error) {
+				return greetResp{}, errors.New("greeting failed")
+			},
+			request:    httptest.NewRequest(http.MethodPost, "/greet", strings.NewReader(`{}`)),
+			wantResp:   httphandler.Response{},
+			wantErrMsg: "greeting failed",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sut := httphandler.JSONHandler[greetReq, greetResp]{
+				Handle:     test.handle,
+				StatusCode: test.statusCode,
+			}
+
+			gotResp, gotErr := sut.ErrPresentHTTP(test.request)
+
+			if got, want := gotResp.StatusCode, test.wantResp.StatusCode; got != want {
+				t.Errorf("got status code %v, wanted %v", got, want)
+			}
+			if got, want := gotResp.Header, test.wantResp.Header; !reflect.DeepEqual(got, want) {
+				t.Errorf("got header mapping %+v, wanted %+v", got, want)
+			}
+			if got, want := string(gotResp.Body), string(test.wantResp.Body); got != want {
+				t.Errorf("got body: %s, wanted: %s", got, want)
+			}
+			if got, want := fmt.Sprintf("%v", gotErr), test.wantErrMsg; got != want {
+				t.Errorf("got error msg: %s, wanted error msg: %s", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package httphandler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+)
+
+// NDJSON returns a 200 response which streams the values from seq as
+// newline delimited JSON (one JSON value per line). Each value is
+// encoded and flushed to the client as soon as seq produces it so the
+// whole sequence never has to be in memory. Since the status code has
+// been sent by the time seq fails (or a value fails to encode) that
+// error ends the stream and is passed to Writer.HandleErr.
+func NDJSON[T any](seq iter.Seq2[T, error]) Response {
+	pr, pw := io.Pipe()
+	go func() {
+		enc := json.NewEncoder(pw)
+		var err error
+		for v, seqErr := range seq {
+			if seqErr != nil {
+				err = seqErr
+				break
+			}
+			// Encode writes the trailing newline. A write only
+			// fails once the stream is closed which is when the
+			// client has gone away.
+			if encErr := enc.Encode(v); encErr != nil {
+				err = fmt.Errorf("encoding value: %w", encErr)
+				break
+			}
+		}
+		pw.CloseWithError(err)
+	}()
+	return Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/x-ndjson"}},
+		Stream:     pr,
+	}
+}
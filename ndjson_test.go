@@ -0,0 +1,146 @@
+package httphandler_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lag13/httphandler"
+)
+
+type item struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// TestNDJSON tests that values get streamed one per line and that
+// errors partway through are passed to HandleErr and abort the
+// response.
+func TestNDJSON(t *testing.T) {
+	tests := []struct {
+		name        string
+		seq         func(yield func(interface{}, error) bool)
+		wantBody    string
+		wantErrMsg  string
+		wantAborted bool
+	}{
+		{
+			name: "every value is written",
+			seq: func(yield func(interface{}, error) bool) {
+				_ = yield(item{ID: 1, Name: "one"}, nil) &&
+					yield(item{ID: 2, Name: "two"}, nil) &&
+					yield("three", nil)
+			},
+			wantBody:   "{\"id\":1,\"name\":\"one\"}\n{\"id\":2,\"name\":\"two\"}\n\"three\"\n",
+			wantErrMsg: "<nil>",
+		},
+		{
+			name: "the source fails",
+			seq: func(yield func(interface{}, error) bool) {
+				_ = yield(item{ID: 1, Name: "one"}, nil) &&
+					yield(nil, errors.New("db connection lost")) &&
+					yield(item{ID: 3, Name: "three"}, nil)
+			},
+			wantBody:    "{\"id\":1,\"name\":\"one\"}\n",
+			wantErrMsg:  "db connection lost",
+			wantAborted: true,
+		},
+		{
+			name: "a value fails to encode",
+			seq: func(yield func(interface{}, error) bool) {
+				_ = yield(item{ID: 1, Name: "one"}, nil) &&
+					yield(func() {}, nil)
+			},
+			wantBody:    "{\"id\":1,\"name\":\"one\"}\n",
+			wantErrMsg:  "encoding value: json: unsupported type: func()",
+			wantAborted: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fnErrHandler := fnToHandleErr{}
+			sut := httphandler.Writer{
+				Presenter: httphandler.PresenterFunc(func(*http.Request) httphandler.Response {
+					return httphandler.NDJSON(test.seq)
+				}),
+				HandleErr: fnErrHandler.handleError,
+			}
+			w := httptest.NewRecorder()
+
+			aborted := serveAborts(sut, w, httptest.NewRequest(http.MethodGet, "/items", nil))
+
+			if got, want := w.Header().Get("Content-Type"), "application/x-ndjson"; got != want {
+				t.Errorf("got content type %s, wanted %s", got, want)
+			}
+			if got, want := w.Body.String(), test.wantBody; got != want {
+				t.Errorf("got body: %q, wanted: %q", got, want)
+			}
+			if got, want := fmt.Sprintf("%v", fnErrHandler.gotErr), test.wantErrMsg; got != want {
+				t.Errorf("got error msg: %s, wanted error msg: %s", got, want)
+			}
+			if got, want := aborted, test.wantAborted; got != want {
+				t.Errorf("got aborted %v, wanted %v", got, want)
+			}
+		})
+	}
+}
+
+// TestNDJSONServerAborts tests that a client reading from a real
+// server sees a truncated response, rather than a complete one, when
+// the source fails partway.
+func TestNDJSONServerAborts(t *testing.T) {
+	gotErr := make(chan error, 1)
+	server := httptest.NewServer(httphandler.Writer{
+		Presenter: httphandler.PresenterFunc(func(*http.Request) httphandler.Response {
+			return httphandler.NDJSON(func(yield func(interface{}, error) bool) {
+				_ = yield(item{ID: 1, Name: "one"}, nil) &&
+					yield(nil, errors.New("db connection lost"))
+			})
+		}),
+		HandleErr: func(_ *http.Request, err error) { gotErr <- err },
+	})
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+
+	if err == nil {
+		t.Error("got nil error reading the body, wanted non-nil")
+	}
+	if got, want := string(body), "{\"id\":1,\"name\":\"one\"}\n"; got != want {
+		t.Errorf("got body: %q, wanted: %q", got, want)
+	}
+	if got, want := fmt.Sprintf("%v", <-gotErr), "db connection lost"; got != want {
+		t.Errorf("got error msg: %s, wanted error msg: %s", got, want)
+	}
+}
+
+// TestNDJSONStops tests that the source stops being iterated once the
+// stream is closed.
+func TestNDJSONStops(t *testing.T) {
+	stopped := make(chan int)
+	resp := httphandler.NDJSON(func(yield func(int, error) bool) {
+		i := 0
+		for yield(i, nil) {
+			i++
+		}
+		stopped <- i
+	})
+	line := make([]byte, 2)
+	if _, err := io.ReadFull(resp.Stream, line); err != nil {
+		t.Fatalf("reading the first line: %v", err)
+	}
+
+	resp.Stream.(io.Closer).Close()
+
+	if got := <-stopped; got > 2 {
+		t.Errorf("the source kept going for %d values", got)
+	}
+}
@@ -0,0 +1,173 @@
+package httphandler
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Encoder encodes values as a particular media type.
+type Encoder struct {
+	MediaType string
+	Encode    func(v interface{}) ([]byte, error)
+}
+
+// Negotiator picks how to encode a value based off the request's
+// Accept header, much like Dispatcher picks a Presenter based off the
+// request's method. Encoders are listed in order of preference which
+// decides between media types the client likes equally.
+type Negotiator struct {
+	Encoders          []Encoder
+	NotAcceptablePres Presenter
+}
+
+// Negotiate returns a response with the given status code whose body
+// is v encoded by the Encoder which best matches the request's Accept
+// header (RFC 9110 section 12.5.1). If no Encoder is acceptable the
+// response comes from NotAcceptablePres instead. Either way the
+// response varies on Accept. An error from encoding v is returned
+// along with the zero Response.
+func (n Negotiator) Negotiate(r *http.Request, statusCode int, v interface{}) (Response, error) {
+	enc, ok := n.choose(r.Header.Values("Accept"))
+	if !ok {
+		resp := n.NotAcceptablePres.PresentHTTP(r)
+		resp.Header = cloneHeader(resp.Header)
+		addVary(resp.Header, "Accept")
+		return resp, nil
+	}
+	body, err := enc.Encode(v)
+	if err != nil {
+		return Response{}, err
+	}
+	return Response{
+		StatusCode: statusCode,
+		Header: http.Header{
+			"Content-Type": {enc.MediaType},
+			"Vary":         {"Accept"},
+		},
+		Body: body,
+	}, nil
+}
+
+// choose returns the most acceptable Encoder.
+func (n Negotiator) choose(accept []string) (Encoder, bool) {
+	if len(accept) == 0 && len(n.Encoders) > 0 {
+		return n.Encoders[0], true
+	}
+	ranges := parseAccept(strings.Join(accept, ","))
+	var best Encoder
+	bestQ := 0.0
+	for _, enc := range n.Encoders {
+		if q := acceptQuality(ranges, enc.MediaType); q > bestQ {
+			best, bestQ = enc, q
+		}
+	}
+	return best, bestQ > 0
+}
+
+// mediaRange is one element of an Accept header.
+type mediaRange struct {
+	typ, subtype string
+	params       map[string]string
+	q            float64
+}
+
+// parseAccept parses an Accept header, skipping malformed elements.
+func parseAccept(accept string) []mediaRange {
+	ranges := []mediaRange{}
+	for _, elem := range strings.Split(accept, ",") {
+		if strings.TrimSpace(elem) == "" {
+			continue
+		}
+		mediaType, params, err := mime.ParseMediaType(elem)
+		if err != nil {
+			continue
+		}
+		typ, subtype, ok := strings.Cut(mediaType, "/")
+		if !ok || (typ == "*" && subtype != "*") {
+			continue
+		}
+		q, ok := parseQ(params)
+		if !ok {
+			continue
+		}
+		delete(params, "q")
+		ranges = append(ranges, mediaRange{typ: typ, subtype: subtype, params: params, q: q})
+	}
+	return ranges
+}
+
+// parseQ returns the "q" parameter which defaults to 1.
+func parseQ(params map[string]string) (float64, bool) {
+	qs, ok := params["q"]
+	if !ok {
+		return 1, true
+	}
+	q, err := strconv.ParseFloat(qs, 64)
+	if err != nil || q < 0 || q > 1 {
+		return 0, false
+	}
+	return q, true
+}
+
+// acceptQuality returns the quality the client assigned to a media
+// type which is the quality of the most specific range matching it.
+func acceptQuality(ranges []mediaRange, mediaType string) float64 {
+	offered, offeredParams, err := mime.ParseMediaType(mediaType)
+	if err != nil {
+		return 0
+	}
+	typ, subtype, _ := strings.Cut(offered, "/")
+	q, specificity := 0.0, -1
+	for _, mr := range ranges {
+		s := 0
+		switch {
+		case mr.typ == typ && mr.subtype == subtype:
+			s = 2
+		case mr.typ == typ && mr.subtype == "*":
+			s = 1
+		case mr.typ == "*":
+			s = 0
+		default:
+			continue
+		}
+		if !paramsMatch(mr.params, offeredParams) {
+			continue
+		}
+		// Ranges with parameters are more specific than the same
+		// range without them.
+		s *= 2
+		if len(mr.params) > 0 {
+			s++
+		}
+		if s > specificity {
+			q, specificity = mr.q, s
+		}
+	}
+	return q
+}
+
+// paramsMatch reports whether every parameter of a media range is
+// present in the offered media type.
+func paramsMatch(rangeParams, offeredParams map[string]string) bool {
+	for name, value := range rangeParams {
+		if !strings.EqualFold(offeredParams[name], value) {
+			return false
+		}
+	}
+	return true
+}
+
+// addVary adds field to the Vary header unless it is already listed.
+func addVary(h http.Header, field string) {
+	for _, value := range h.Values("Vary") {
+		for _, existing := range strings.Split(value, ",") {
+			existing = strings.TrimSpace(existing)
+			if existing == "*" || strings.EqualFold(existing, field) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", field)
+}
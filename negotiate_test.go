@@ -0,0 +1,148 @@
+package httphandler_test
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/lag13/httphandler"
+)
+
+// TestNegotiator tests that the Negotiator picks the encoder which
+// best matches the Accept header.
+func TestNegotiator(t *testing.T) {
+	sut := httphandler.Negotiator{
+		Encoders: []httphandler.Encoder{
+			{MediaType: "application/json", Encode: json.Marshal},
+			{MediaType: "application/xml", Encode: xml.Marshal},
+			{MediaType: "text/csv; charset=utf-8", Encode: func(v interface{}) ([]byte, error) {
+				return []byte(fmt.Sprintf("%v\n", v)), nil
+			}},
+		},
+		NotAcceptablePres: httphandler.PresenterFunc(func(r *http.Request) httphandler.Response {
+			return httphandler.Response{StatusCode: http.StatusNotAcceptable}
+		}),
+	}
+	tests := []struct {
+		name            string
+		accept          []string
+		wantStatusCode  int
+		wantContentType string
+		wantBody        string
+	}{
+		{
+			name:            "no Accept header picks the first encoder",
+			accept:          nil,
+			wantStatusCode:  200,
+			wantContentType: "application/json",
+			wantBody:        `"hi"`,
+		},
+		{
+			name:            "exact match",
+			accept:          []string{"application/xml"},
+			wantStatusCode:  200,
+			wantContentType: "application/xml",
+			wantBody:        "<string>hi</string>",
+		},
+		{
+			name:            "highest quality wins",
+			accept:          []string{"application/json;q=0.5, text/csv;q=0.8, application/xml;q=0.1"},
+			wantStatusCode:  200,
+			wantContentType: "text/csv; charset=utf-8",
+			wantBody:        "hi\n",
+		},
+		{
+			name:            "ties go to the preferred encoder",
+			accept:          []string{"application/xml, application/json"},
+			wantStatusCode:  200,
+			wantContentType: "application/json",
+			wantBody:        `"hi"`,
+		},
+		{
+			name:            "subtype wildcard",
+			accept:          []string{"text/*"},
+			wantStatusCode:  200,
+			wantContentType: "text/csv; charset=utf-8",
+			wantBody:        "hi\n",
+		},
+		{
+			name:            "the most specific range decides the quality",
+			accept:          []string{"*/*;q=0.9", "application/json;q=0"},
+			wantStatusCode:  200,
+			wantContentType: "application/xml",
+			wantBody:        "<string>hi</string>",
+		},
+		{
+			name:            "media range parameters must match",
+			accept:          []string{"text/csv;charset=latin1, application/xml;q=0.1"},
+			wantStatusCode:  200,
+			wantContentType: "application/xml",
+			wantBody:        "<string>hi</string>",
+		},
+		{
+			name:            "malformed elements are ignored",
+			accept:          []string{"garbage, */json, application/xml;q=2, application/xml;q=0.3"},
+			wantStatusCode:  200,
+			wantContentType: "application/xml",
+			wantBody:        "<string>hi</string>",
+		},
+		{
+			name:            "nothing is acceptable",
+			accept:          []string{"image/png"},
+			wantStatusCode:  http.StatusNotAcceptable,
+			wantContentType: "",
+			wantBody:        "",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/negotiate", nil)
+			for _, accept := range test.accept {
+				req.Header.Add("Accept", accept)
+			}
+
+			gotResp, err := sut.Negotiate(req, 200, "hi")
+
+			if err != nil {
+				t.Fatalf("got unexpected error: %v", err)
+			}
+			if got, want := gotResp.StatusCode, test.wantStatusCode; got != want {
+				t.Errorf("got status code %v, wanted %v", got, want)
+			}
+			if got, want := gotResp.Header.Get("Content-Type"), test.wantContentType; got != want {
+				t.Errorf("got content type %q, wanted %q", got, want)
+			}
+			if got, want := gotResp.Header.Values("Vary"), []string{"Accept"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("got vary %v, wanted %v", got, want)
+			}
+			if got, want := string(gotResp.Body), test.wantBody; got != want {
+				t.Errorf("got body: %s, wanted: %s", got, want)
+			}
+		})
+	}
+}
+
+// TestNegotiatorEncodeFails tests that an encoding error is returned.
+func TestNegotiatorEncodeFails(t *testing.T) {
+	sut := httphandler.Negotiator{
+		Encoders: []httphandler.Encoder{
+			{MediaType: "application/json", Encode: func(interface{}) ([]byte, error) {
+				return nil, errors.New("cannot encode")
+			}},
+		},
+	}
+
+	gotResp, err := sut.Negotiate(httptest.NewRequest(http.MethodGet, "/negotiate", nil), 200, "hi")
+
+	if got, want := fmt.Sprintf("%v", err), "cannot encode"; got != want {
+		t.Errorf("got error msg: %s, wanted error msg: %s", got, want)
+	}
+	if got, want := gotResp.StatusCode, 0; got != want {
+		t.Errorf("got status code %v, wanted %v", got, want)
+	}
+}
@@ -0,0 +1,113 @@
+package httphandler
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Problem is an RFC 9457 problem details object. It is an error so
+// it can be returned from an ErrPresenter (see ErrProblem) and it is
+// a Presenter so it can also be used directly as, for example, the
+// MethodNotSupportedPres of a Dispatcher or the DefaultPresenter of a
+// DefaultResp.
+type Problem struct {
+	Type     string
+	Title    string
+	Status   int
+	Detail   string
+	Instance string
+	// Extensions are additional members of the problem details
+	// object.
+	Extensions map[string]interface{}
+	// Err is the underlying cause of the problem. It is not
+	// included in the response.
+	Err error
+}
+
+func (p *Problem) Error() string {
+	msg := p.title()
+	if p.Detail != "" {
+		msg += ": " + p.Detail
+	}
+	if p.Err != nil {
+		msg += ": " + p.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap returns the underlying cause of the problem.
+func (p *Problem) Unwrap() error {
+	return p.Err
+}
+
+// MarshalJSON encodes the problem as a single JSON object with the
+// extension members alongside the standard ones.
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	members := map[string]interface{}{}
+	for name, value := range p.Extensions {
+		members[name] = value
+	}
+	if p.Type != "" {
+		members["type"] = p.Type
+	}
+	if title := p.title(); title != "" {
+		members["title"] = title
+	}
+	members["status"] = p.status()
+	if p.Detail != "" {
+		members["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		members["instance"] = p.Instance
+	}
+	return json.Marshal(members)
+}
+
+// Response returns the problem as an application/problem+json
+// response.
+func (p *Problem) Response() Response {
+	body, err := json.Marshal(p)
+	if err != nil {
+		// Only the extensions can fail to encode so fall back
+		// to the standard members.
+		withoutExtensions := *p
+		withoutExtensions.Extensions = nil
+		body, _ = json.Marshal(&withoutExtensions)
+	}
+	return Response{
+		StatusCode: p.status(),
+		Header:     http.Header{"Content-Type": {"application/problem+json"}},
+		Body:       body,
+	}
+}
+
+// PresentHTTP returns p.Response().
+func (p *Problem) PresentHTTP(*http.Request) Response {
+	return p.Response()
+}
+
+// status returns the status code, defaulting to 500.
+func (p *Problem) status() int {
+	if p.Status == 0 {
+		return http.StatusInternalServerError
+	}
+	return p.Status
+}
+
+// title returns the title. The RFC says that when the type is
+// "about:blank" (which is what an empty type means) the title should
+// be the same as the status code's reason phrase.
+func (p *Problem) title() string {
+	if p.Title == "" && (p.Type == "" || p.Type == "about:blank") {
+		return http.StatusText(p.status())
+	}
+	return p.Title
+}
+
+// ErrProblem returns an ErrMapping which renders any *Problem error
+// as its response.
+func ErrProblem() ErrMapping {
+	return ErrAs(func(p *Problem) Response {
+		return p.Response()
+	})
+}
@@ -0,0 +1,108 @@
+package httphandler_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/lag13/httphandler"
+)
+
+// TestProblem tests that a Problem gets rendered as the expected
+// application/problem+json response.
+func TestProblem(t *testing.T) {
+	tests := []struct {
+		name           string
+		problem        *httphandler.Problem
+		wantStatusCode int
+		wantBody       string
+		wantErrMsg     string
+	}{
+		{
+			name:           "zero value is a generic 500",
+			problem:        &httphandler.Problem{},
+			wantStatusCode: 500,
+			wantBody:       `{"status":500,"title":"Internal Server Error"}`,
+			wantErrMsg:     "Internal Server Error",
+		},
+		{
+			name: "all the standard members",
+			problem: &httphandler.Problem{
+				Type:     "https://example.com/probs/out-of-credit",
+				Title:    "You do not have enough credit.",
+				Status:   403,
+				Detail:   "Your current balance is 30, but that costs 50.",
+				Instance: "/account/12345/msgs/abc",
+			},
+			wantStatusCode: 403,
+			wantBody:       `{"detail":"Your current balance is 30, but that costs 50.","instance":"/account/12345/msgs/abc","status":403,"title":"You do not have enough credit.","type":"https://example.com/probs/out-of-credit"}`,
+			wantErrMsg:     "You do not have enough credit.: Your current balance is 30, but that costs 50.",
+		},
+		{
+			name: "extension members and an underlying error",
+			problem: &httphandler.Problem{
+				Status:     404,
+				Extensions: map[string]interface{}{"id": 7, "status": "ignored"},
+				Err:        errors.New("sql: no rows in result set"),
+			},
+			wantStatusCode: 404,
+			wantBody:       `{"id":7,"status":404,"title":"Not Found"}`,
+			wantErrMsg:     "Not Found: sql: no rows in result set",
+		},
+		{
+			name: "extension members which cannot be encoded are dropped",
+			problem: &httphandler.Problem{
+				Status:     400,
+				Extensions: map[string]interface{}{"fn": func() {}},
+			},
+			wantStatusCode: 400,
+			wantBody:       `{"status":400,"title":"Bad Request"}`,
+			wantErrMsg:     "Bad Request",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gotResp := test.problem.PresentHTTP(httptest.NewRequest(http.MethodGet, "/problem", nil))
+
+			if got, want := gotResp.StatusCode, test.wantStatusCode; got != want {
+				t.Errorf("got status code %v, wanted %v", got, want)
+			}
+			if got, want := gotResp.Header, (http.Header{"Content-Type": {"application/problem+json"}}); !reflect.DeepEqual(got, want) {
+				t.Errorf("got header mapping %+v, wanted %+v", got, want)
+			}
+			if got, want := string(gotResp.Body), test.wantBody; got != want {
+				t.Errorf("got body: %s, wanted: %s", got, want)
+			}
+			if got, want := test.problem.Error(), test.wantErrMsg; got != want {
+				t.Errorf("got error msg: %s, wanted error msg: %s", got, want)
+			}
+		})
+	}
+}
+
+// TestErrProblem tests that problems returned from an ErrPresenter get
+// rendered by ErrMapper.
+func TestErrProblem(t *testing.T) {
+	errCause := errors.New("cause")
+	sut := httphandler.ErrMapper{
+		ErrPresenter: httphandler.ErrPresenterFunc(func(*http.Request) (httphandler.Response, error) {
+			return httphandler.Response{}, fmt.Errorf("wrapped: %w", &httphandler.Problem{Status: 409, Err: errCause})
+		}),
+		Mappings: []httphandler.ErrMapping{httphandler.ErrProblem()},
+	}
+
+	gotResp, gotErr := sut.ErrPresentHTTP(httptest.NewRequest(http.MethodPut, "/problem", nil))
+
+	if got, want := gotResp.StatusCode, 409; got != want {
+		t.Errorf("got status code %v, wanted %v", got, want)
+	}
+	if got, want := string(gotResp.Body), `{"status":409,"title":"Conflict"}`; got != want {
+		t.Errorf("got body: %s, wanted: %s", got, want)
+	}
+	if !errors.Is(gotErr, errCause) {
+		t.Errorf("got error %v which does not wrap the cause", gotErr)
+	}
+}
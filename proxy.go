@@ -0,0 +1,190 @@
+package httphandler
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Proxy is an ErrPresenter which forwards requests to an upstream
+// server and returns its reply. The request's path and query are
+// appended to those of Upstream and its Host header becomes the
+// upstream host (Rewrite can change that and anything else about the
+// outgoing request before it is sent).
+//
+// Hop-by-hop headers (Connection and the headers it lists,
+// Keep-Alive, Upgrade and so on) are removed from the request and the
+// reply. The client's address is added to X-Forwarded-For and the
+// request's Host and scheme are sent as X-Forwarded-Host and
+// X-Forwarded-Proto. Any Forwarded or X-Forwarded-* headers the
+// request already had are dropped first, since any client could have
+// sent them, unless TrustForwarded is set (because the request comes
+// from another proxy) in which case they are kept and added to.
+//
+// Replies whose bodies are at most MaxBufferSize bytes (a
+// MaxBufferSize of 0 means 64KiB) are buffered and other replies are
+// streamed. Trailers are passed along either way.
+type Proxy struct {
+	Upstream *url.URL
+	// Transport sends the outgoing requests. It defaults to
+	// http.DefaultTransport.
+	Transport      http.RoundTripper
+	Rewrite        func(out, in *http.Request)
+	ModifyResponse func(*http.Response) error
+	TrustForwarded bool
+	MaxBufferSize  int64
+}
+
+// hopHeaders are the hop-by-hop headers which are removed from
+// requests and replies on top of those listed in the Connection
+// header. Te is treated specially since "Te: trailers" needs to be
+// passed on for trailers to work with some upstreams.
+var hopHeaders = []string{
+	"Connection",
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// ErrPresentHTTP forwards the request upstream and returns the reply.
+// The zero Response is returned along with an error if the upstream
+// cannot be reached, its body cannot be read or ModifyResponse fails
+// (so DefaultResp can supply the response, such as a 502).
+func (p Proxy) ErrPresentHTTP(r *http.Request) (Response, error) {
+	out := p.outgoing(r)
+	transport := p.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	upResp, err := transport.RoundTrip(out)
+	if err != nil {
+		return Response{}, fmt.Errorf("proxying to %s: %w", p.Upstream.Host, err)
+	}
+	removeHopHeaders(upResp.Header)
+	if p.ModifyResponse != nil {
+		if err := p.ModifyResponse(upResp); err != nil {
+			upResp.Body.Close()
+			return Response{}, fmt.Errorf("proxying to %s: modifying response: %w", p.Upstream.Host, err)
+		}
+	}
+	resp := Response{
+		StatusCode: upResp.StatusCode,
+		Header:     upResp.Header,
+	}
+	// The client fills in the values of the trailers once the body
+	// has been read.
+	if len(upResp.Trailer) > 0 {
+		resp.Trailer = upResp.Trailer
+	}
+	maxBufferSize := p.MaxBufferSize
+	if maxBufferSize == 0 {
+		maxBufferSize = 64 << 10
+	}
+	if upResp.ContentLength < 0 || upResp.ContentLength > maxBufferSize {
+		resp.Stream = upResp.Body
+		return resp, nil
+	}
+	defer upResp.Body.Close()
+	body, err := io.ReadAll(upResp.Body)
+	if err != nil {
+		return Response{}, fmt.Errorf("proxying to %s: reading response body: %w", p.Upstream.Host, err)
+	}
+	resp.Body = body
+	return resp, nil
+}
+
+// outgoing returns the request to send upstream.
+func (p Proxy) outgoing(r *http.Request) *http.Request {
+	out := r.Clone(r.Context())
+	out.RequestURI = ""
+	out.Host = ""
+	out.Close = false
+	if r.ContentLength == 0 {
+		out.Body = nil
+	}
+	out.URL.Scheme = p.Upstream.Scheme
+	out.URL.Host = p.Upstream.Host
+	out.URL.Path = joinURLPath(p.Upstream.Path, r.URL.Path)
+	out.URL.RawPath = ""
+	if p.Upstream.RawPath != "" || r.URL.RawPath != "" {
+		out.URL.RawPath = joinURLPath(p.Upstream.EscapedPath(), r.URL.EscapedPath())
+	}
+	if p.Upstream.RawQuery == "" || r.URL.RawQuery == "" {
+		out.URL.RawQuery = p.Upstream.RawQuery + r.URL.RawQuery
+	} else {
+		out.URL.RawQuery = p.Upstream.RawQuery + "&" + r.URL.RawQuery
+	}
+
+	teTrailers := headerHasToken(out.Header, "Te", "trailers")
+	removeHopHeaders(out.Header)
+	if teTrailers {
+		out.Header.Set("Te", "trailers")
+	}
+	// Otherwise the Go client's default User-Agent would be sent.
+	if _, ok := out.Header["User-Agent"]; !ok {
+		out.Header.Set("User-Agent", "")
+	}
+
+	if !p.TrustForwarded {
+		for _, name := range []string{"Forwarded", "X-Forwarded-For", "X-Forwarded-Host", "X-Forwarded-Proto"} {
+			out.Header.Del(name)
+		}
+	}
+	clientIP, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		clientIP = r.RemoteAddr
+	}
+	if clientIP != "" {
+		forwardedFor := append(out.Header.Values("X-Forwarded-For"), clientIP)
+		out.Header.Set("X-Forwarded-For", strings.Join(forwardedFor, ", "))
+	}
+	if out.Header.Get("X-Forwarded-Host") == "" {
+		out.Header.Set("X-Forwarded-Host", r.Host)
+	}
+	if out.Header.Get("X-Forwarded-Proto") == "" {
+		proto := "http"
+		if r.TLS != nil {
+			proto = "https"
+		}
+		out.Header.Set("X-Forwarded-Proto", proto)
+	}
+
+	if p.Rewrite != nil {
+		p.Rewrite(out, r)
+	}
+	return out
+}
+
+// removeHopHeaders removes the hop-by-hop headers from header.
+func removeHopHeaders(header http.Header) {
+	for _, value := range header.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				header.Del(name)
+			}
+		}
+	}
+	for _, name := range hopHeaders {
+		header.Del(name)
+	}
+}
+
+// joinURLPath joins a base path and a request path with a single
+// slash.
+func joinURLPath(base, reqPath string) string {
+	if base == "" {
+		return reqPath
+	}
+	if reqPath == "" {
+		return base
+	}
+	return strings.TrimSuffix(base, "/") + "/" + strings.TrimPrefix(reqPath, "/")
+}
@@ -0,0 +1,301 @@
+package httphandler_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/lag13/httphandler"
+)
+
+// roundTripperFunc allows the use of ordinary functions as
+// http.RoundTripper's.
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// TestProxyRequest tests that the request sent upstream has the right
+// URL and headers.
+func TestProxyRequest(t *testing.T) {
+	tests := []struct {
+		name           string
+		upstream       string
+		target         string
+		reqHeader      http.Header
+		trustForwarded bool
+		rewrite        func(out, in *http.Request)
+		wantURL        string
+		wantHost       string
+		wantHeader     http.Header
+	}{
+		{
+			name:     "joins the paths and queries",
+			upstream: "/api?key=1",
+			target:   "/users/42?page=2",
+			wantURL:  "/api/users/42?key=1&page=2",
+			wantHeader: http.Header{
+				"X-Forwarded-For":   {"192.0.2.1"},
+				"X-Forwarded-Host":  {"example.com"},
+				"X-Forwarded-Proto": {"http"},
+			},
+		},
+		{
+			name:     "keeps escaped paths",
+			upstream: "/",
+			target:   "/files/a%2Fb",
+			wantURL:  "/files/a%2Fb",
+		},
+		{
+			name:   "removes hop-by-hop headers",
+			target: "/",
+			reqHeader: http.Header{
+				"Connection":    {"X-Hop, close"},
+				"X-Hop":         {"1"},
+				"Keep-Alive":    {"timeout=5"},
+				"Upgrade":       {"websocket"},
+				"Te":            {"gzip, trailers"},
+				"Authorization": {"Bearer token"},
+			},
+			wantURL: "/",
+			wantHeader: http.Header{
+				"Connection":    nil,
+				"X-Hop":         nil,
+				"Keep-Alive":    nil,
+				"Upgrade":       nil,
+				"Te":            {"trailers"},
+				"Authorization": {"Bearer token"},
+			},
+		},
+		{
+			name:   "drops untrusted forwarding headers",
+			target: "/",
+			reqHeader: http.Header{
+				"Forwarded":         {"for=198.51.100.1"},
+				"X-Forwarded-For":   {"198.51.100.1"},
+				"X-Forwarded-Host":  {"evil.example"},
+				"X-Forwarded-Proto": {"https"},
+			},
+			wantURL: "/",
+			wantHeader: http.Header{
+				"Forwarded":         nil,
+				"X-Forwarded-For":   {"192.0.2.1"},
+				"X-Forwarded-Host":  {"example.com"},
+				"X-Forwarded-Proto": {"http"},
+			},
+		},
+		{
+			name:   "adds to trusted forwarding headers",
+			target: "/",
+			reqHeader: http.Header{
+				"X-Forwarded-For":   {"198.51.100.1"},
+				"X-Forwarded-Host":  {"public.example"},
+				"X-Forwarded-Proto": {"https"},
+			},
+			trustForwarded: true,
+			wantURL:        "/",
+			wantHeader: http.Header{
+				"X-Forwarded-For":   {"198.51.100.1, 192.0.2.1"},
+				"X-Forwarded-Host":  {"public.example"},
+				"X-Forwarded-Proto": {"https"},
+			},
+		},
+		{
+			name:   "rewrite",
+			target: "/old",
+			rewrite: func(out, in *http.Request) {
+				out.URL.Path = "/new"
+				out.Host = in.Host
+				out.Header.Set("X-Rewritten", "yes")
+			},
+			wantURL:    "/new",
+			wantHost:   "example.com",
+			wantHeader: http.Header{"X-Rewritten": {"yes"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var gotReq *http.Request
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotReq = r
+			}))
+			defer upstream.Close()
+			upstreamURL, _ := url.Parse(upstream.URL + test.upstream)
+			sut := httphandler.Proxy{
+				Upstream:       upstreamURL,
+				TrustForwarded: test.trustForwarded,
+				Rewrite:        test.rewrite,
+			}
+			req := httptest.NewRequest(http.MethodGet, test.target, nil)
+			for name, values := range test.reqHeader {
+				req.Header[name] = values
+			}
+
+			if _, err := sut.ErrPresentHTTP(req); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got, want := gotReq.RequestURI, test.wantURL; got != want {
+				t.Errorf("got request URI %s, wanted %s", got, want)
+			}
+			wantHost := test.wantHost
+			if wantHost == "" {
+				wantHost = upstreamURL.Host
+			}
+			if got := gotReq.Host; got != wantHost {
+				t.Errorf("got host %s, wanted %s", got, wantHost)
+			}
+			for name, want := range test.wantHeader {
+				if got := gotReq.Header.Values(name); !reflect.DeepEqual(got, want) {
+					t.Errorf("got %s header %v, wanted %v", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+// TestProxyResponse tests that the upstream's reply is converted into
+// a Response which is buffered if it is small and streamed otherwise.
+func TestProxyResponse(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Connection", "X-Hop")
+		w.Header().Set("X-Hop", "1")
+		w.Header().Set("X-Method", r.Method)
+		w.Header().Set("Content-Length", fmt.Sprint(len(body)))
+		w.WriteHeader(http.StatusCreated)
+		w.Write(body)
+	}))
+	defer upstream.Close()
+	upstreamURL, _ := url.Parse(upstream.URL)
+	tests := []struct {
+		name       string
+		body       string
+		wantStream bool
+	}{
+		{
+			name: "small bodies are buffered",
+			body: "hello",
+		},
+		{
+			name:       "large bodies are streamed",
+			body:       strings.Repeat("x", 100),
+			wantStream: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sut := httphandler.Proxy{Upstream: upstreamURL, MaxBufferSize: 10}
+
+			gotResp, err := sut.ErrPresentHTTP(httptest.NewRequest(http.MethodPut, "/", strings.NewReader(test.body)))
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got, want := gotResp.StatusCode, 201; got != want {
+				t.Errorf("got status code %v, wanted %v", got, want)
+			}
+			if got, want := gotResp.Header.Get("X-Method"), "PUT"; got != want {
+				t.Errorf("got X-Method header %s, wanted %s", got, want)
+			}
+			if got := gotResp.Header.Get("X-Hop"); got != "" {
+				t.Errorf("got hop-by-hop header X-Hop: %s, wanted none", got)
+			}
+			if got := gotResp.Stream != nil; got != test.wantStream {
+				t.Errorf("got streamed %v, wanted %v", got, test.wantStream)
+			}
+			if got, want := readBody(t, gotResp), test.body; got != want {
+				t.Errorf("got body: %s, wanted: %s", got, want)
+			}
+		})
+	}
+}
+
+// TestProxyTrailers tests that trailers from the upstream reach the
+// client.
+func TestProxyTrailers(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", "Checksum")
+		io.WriteString(w, "data")
+		w.Header().Set("Checksum", "abc")
+	}))
+	defer upstream.Close()
+	upstreamURL, _ := url.Parse(upstream.URL)
+	writer := httphandler.Writer{
+		Presenter: httphandler.ErrHandler{
+			ErrPresenter: httphandler.Proxy{Upstream: upstreamURL},
+			HandleErr:    func(_ *http.Request, err error) { t.Errorf("unexpected error: %v", err) },
+		},
+		HandleErr: func(_ *http.Request, err error) { t.Errorf("unexpected error: %v", err) },
+	}
+	w := httptest.NewRecorder()
+
+	writer.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if got, want := w.Body.String(), "data"; got != want {
+		t.Errorf("got body: %s, wanted: %s", got, want)
+	}
+	if got, want := w.Result().Trailer, (http.Header{"Checksum": {"abc"}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got trailers %v, wanted %v", got, want)
+	}
+}
+
+// TestProxyFails tests that failing to reach the upstream or a
+// failing ModifyResponse results in the zero Response and an error.
+func TestProxyFails(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	upstreamURL, _ := url.Parse(upstream.URL)
+	modifyErr := errors.New("rejected")
+	tests := []struct {
+		name  string
+		proxy httphandler.Proxy
+	}{
+		{
+			name: "upstream unreachable",
+			proxy: httphandler.Proxy{
+				Upstream: upstreamURL,
+				Transport: roundTripperFunc(func(*http.Request) (*http.Response, error) {
+					return nil, errors.New("connection refused")
+				}),
+			},
+		},
+		{
+			name: "modify response fails",
+			proxy: httphandler.Proxy{
+				Upstream:       upstreamURL,
+				ModifyResponse: func(*http.Response) error { return modifyErr },
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gotResp, err := test.proxy.ErrPresentHTTP(httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if err == nil {
+				t.Error("got nil error, wanted non-nil")
+			}
+			if got, want := gotResp.StatusCode, 0; got != want {
+				t.Errorf("got status code %v, wanted %v", got, want)
+			}
+		})
+	}
+	upstream.Close()
+
+	t.Run("upstream closed", func(t *testing.T) {
+		gotResp, err := httphandler.Proxy{Upstream: upstreamURL}.ErrPresentHTTP(httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if err == nil {
+			t.Error("got nil error, wanted non-nil")
+		}
+		if got, want := gotResp.StatusCode, 0; got != want {
+			t.Errorf("got status code %v, wanted %v", got, want)
+		}
+	})
+}
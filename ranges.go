@@ -0,0 +1,264 @@
+package httphandler
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// Ranges is a Presenter which serves parts of the response of another
+// Presenter according to the Range and If-Range headers of GET
+// requests as described in RFC 9110 section 14. Only 200 responses
+// whose size is known up front can be served in parts. Those are
+// responses with a buffered Body and responses whose Stream
+// implements both io.ReaderAt and io.Seeker (as *os.File does). Such
+// responses advertise "Accept-Ranges: bytes".
+//
+// A request for a single range gets a 206 response with that part of
+// the body, a request for several ranges gets a 206
+// multipart/byteranges response and a request whose ranges are all
+// beyond the end of the body gets a 416 response. Range headers which
+// are malformed, ask for more than MaxRanges ranges or would result
+// in sending more than the whole body (because the ranges overlap)
+// are ignored and the whole response is returned.
+//
+// If-Range compares against the response's ETag and Last-Modified
+// headers so Conditional should be placed inside Ranges if it is
+// supposed to compute an ETag.
+type Ranges struct {
+	Presenter Presenter
+	// MaxRanges is the most ranges a single request can ask for.
+	// Zero means there is no limit.
+	MaxRanges int
+}
+
+// byteRange is a satisfiable range of a body.
+type byteRange struct {
+	start, length int64
+}
+
+// contentRange returns the Content-Range header value of the range.
+func (br byteRange) contentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", br.start, br.start+br.length-1, size)
+}
+
+// PresentHTTP returns the requested ranges of the response from a
+// Presenter or the whole response if there is no (usable) Range
+// header.
+func (rg Ranges) PresentHTTP(r *http.Request) Response {
+	resp := rg.Presenter.PresentHTTP(r)
+	if resp.isZero() || (resp.StatusCode != 0 && resp.StatusCode != http.StatusOK) {
+		return resp
+	}
+	content, size, ok := rangeContent(resp)
+	if !ok {
+		return resp
+	}
+	resp.Header = cloneHeader(resp.Header)
+	if resp.Header.Get("Accept-Ranges") == "" {
+		resp.Header.Set("Accept-Ranges", "bytes")
+	}
+	rangeHeader := r.Header.Get("Range")
+	if r.Method != http.MethodGet || rangeHeader == "" || !ifRangeMatches(r.Header.Get("If-Range"), resp.Header) {
+		return resp
+	}
+	ranges, ok := parseRanges(rangeHeader, size)
+	if !ok || (rg.MaxRanges > 0 && len(ranges) > rg.MaxRanges) {
+		return resp
+	}
+	if len(ranges) == 0 {
+		closeStream(resp)
+		return Response{
+			StatusCode: http.StatusRequestedRangeNotSatisfiable,
+			Header:     http.Header{"Content-Range": {fmt.Sprintf("bytes */%d", size)}},
+		}
+	}
+	var total int64
+	for _, br := range ranges {
+		total += br.length
+	}
+	if total > size {
+		return resp
+	}
+
+	resp.StatusCode = http.StatusPartialContent
+	resp.Header.Del("Content-Length")
+	if len(ranges) == 1 {
+		br := ranges[0]
+		resp.Header.Set("Content-Range", br.contentRange(size))
+		if resp.Stream == nil {
+			resp.Body = resp.Body[br.start : br.start+br.length]
+			return resp
+		}
+		resp.Header.Set("Content-Length", strconv.FormatInt(br.length, 10))
+		resp.Stream = rangeStream{Reader: io.NewSectionReader(content, br.start, br.length), orig: resp.Stream}
+		return resp
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if resp.Stream == nil {
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		// Writing to a bytes.Buffer cannot fail.
+		writeByteRanges(mw, content, ranges, contentType, size)
+		resp.Header.Set("Content-Type", "multipart/byteranges; boundary="+mw.Boundary())
+		resp.Body = buf.Bytes()
+		return resp
+	}
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		pw.CloseWithError(writeByteRanges(mw, content, ranges, contentType, size))
+	}()
+	resp.Header.Set("Content-Type", "multipart/byteranges; boundary="+mw.Boundary())
+	resp.Stream = rangeStream{Reader: pr, orig: resp.Stream}
+	return resp
+}
+
+// rangeContent returns the content of the response as an io.ReaderAt
+// along with its size if parts of it can be served.
+func rangeContent(resp Response) (io.ReaderAt, int64, bool) {
+	if resp.Hijack != nil {
+		return nil, 0, false
+	}
+	if resp.Stream == nil {
+		return bytes.NewReader(resp.Body), int64(len(resp.Body)), true
+	}
+	content, ok := resp.Stream.(interface {
+		io.ReaderAt
+		io.Seeker
+	})
+	if !ok {
+		return nil, 0, false
+	}
+	// Seeking tells us the size of the stream and also puts it back
+	// at the start in case the whole thing ends up being served.
+	size, err := content.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, 0, false
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return nil, 0, false
+	}
+	return content, size, true
+}
+
+// writeByteRanges writes each range of content as a part of a
+// multipart/byteranges body.
+func writeByteRanges(mw *multipart.Writer, content io.ReaderAt, ranges []byteRange, contentType string, size int64) error {
+	for _, br := range ranges {
+		header := textproto.MIMEHeader{"Content-Range": {br.contentRange(size)}}
+		if contentType != "" {
+			header.Set("Content-Type", contentType)
+		}
+		part, err := mw.CreatePart(header)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(part, io.NewSectionReader(content, br.start, br.length)); err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+// ifRangeMatches reports whether an If-Range header (which could be
+// empty) allows the Range header to be used with a response that has
+// the given header. An entity tag must match the ETag using the
+// strong comparison and a date must exactly match Last-Modified.
+func ifRangeMatches(ifRange string, header http.Header) bool {
+	ifRange = strings.TrimSpace(ifRange)
+	if ifRange == "" {
+		return true
+	}
+	if strings.HasPrefix(ifRange, `"`) || strings.HasPrefix(ifRange, "W/") {
+		etag := header.Get("ETag")
+		return etag != "" && etagsMatch(ifRange, etag, true)
+	}
+	date, err := http.ParseTime(ifRange)
+	if err != nil {
+		return false
+	}
+	lastModified, err := http.ParseTime(header.Get("Last-Modified"))
+	return err == nil && date.Equal(lastModified)
+}
+
+// parseRanges parses a Range header for a body of the given size. It
+// returns false if the header is malformed or uses a unit other than
+// bytes. The returned ranges are the satisfiable ones so an empty
+// list means none of them are.
+func parseRanges(header string, size int64) ([]byteRange, bool) {
+	unit, specs, ok := strings.Cut(header, "=")
+	if !ok || !strings.EqualFold(strings.TrimSpace(unit), "bytes") {
+		return nil, false
+	}
+	var ranges []byteRange
+	sawSpec := false
+	for _, spec := range strings.Split(specs, ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		sawSpec = true
+		first, last, ok := strings.Cut(spec, "-")
+		if !ok {
+			return nil, false
+		}
+		first, last = strings.TrimSpace(first), strings.TrimSpace(last)
+		if first == "" {
+			// A suffix range like -500 asks for the last 500
+			// bytes.
+			n, err := strconv.ParseInt(last, 10, 64)
+			if err != nil || n < 0 {
+				return nil, false
+			}
+			if n == 0 || size == 0 {
+				continue
+			}
+			if n > size {
+				n = size
+			}
+			ranges = append(ranges, byteRange{start: size - n, length: n})
+			continue
+		}
+		start, err := strconv.ParseInt(first, 10, 64)
+		if err != nil || start < 0 {
+			return nil, false
+		}
+		end := size - 1
+		if last != "" {
+			end, err = strconv.ParseInt(last, 10, 64)
+			if err != nil || end < start {
+				return nil, false
+			}
+			if end > size-1 {
+				end = size - 1
+			}
+		}
+		if start >= size {
+			continue
+		}
+		ranges = append(ranges, byteRange{start: start, length: end - start + 1})
+	}
+	if !sawSpec {
+		return nil, false
+	}
+	return ranges, true
+}
+
+// rangeStream is a stream made from part of another stream. Closing
+// it closes that other stream too.
+type rangeStream struct {
+	io.Reader
+	orig io.Reader
+}
+
+func (s rangeStream) Close() error {
+	closeStream(Response{Stream: s.Reader})
+	return closeStream(Response{Stream: s.orig})
+}
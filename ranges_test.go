@@ -0,0 +1,297 @@
+package httphandler_test
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/lag13/httphandler"
+)
+
+// TestRanges tests that Ranges serves the parts of a response that
+// the Range header asks for when it can and the whole response when
+// it cannot.
+func TestRanges(t *testing.T) {
+	const body = "0123456789abcdefghij"
+	tests := []struct {
+		name             string
+		method           string
+		reqHeader        http.Header
+		resp             httphandler.Response
+		maxRanges        int
+		wantStatusCode   int
+		wantHeader       http.Header
+		wantBody         string
+		wantAcceptRanges bool
+	}{
+		{
+			name:             "no range",
+			resp:             httphandler.Response{StatusCode: 200, Body: []byte(body)},
+			wantStatusCode:   200,
+			wantBody:         body,
+			wantAcceptRanges: true,
+		},
+		{
+			name:             "single range",
+			reqHeader:        http.Header{"Range": {"bytes=2-5"}},
+			resp:             httphandler.Response{StatusCode: 200, Body: []byte(body)},
+			wantStatusCode:   206,
+			wantHeader:       http.Header{"Content-Range": {"bytes 2-5/20"}},
+			wantBody:         "2345",
+			wantAcceptRanges: true,
+		},
+		{
+			name:             "open ended range",
+			reqHeader:        http.Header{"Range": {"bytes=15-"}},
+			resp:             httphandler.Response{Body: []byte(body)},
+			wantStatusCode:   206,
+			wantHeader:       http.Header{"Content-Range": {"bytes 15-19/20"}},
+			wantBody:         "fghij",
+			wantAcceptRanges: true,
+		},
+		{
+			name:             "suffix range",
+			reqHeader:        http.Header{"Range": {"bytes=-3"}},
+			resp:             httphandler.Response{StatusCode: 200, Body: []byte(body)},
+			wantStatusCode:   206,
+			wantHeader:       http.Header{"Content-Range": {"bytes 17-19/20"}},
+			wantBody:         "hij",
+			wantAcceptRanges: true,
+		},
+		{
+			name:             "range past the end is truncated",
+			reqHeader:        http.Header{"Range": {"bytes=18-100"}},
+			resp:             httphandler.Response{StatusCode: 200, Body: []byte(body)},
+			wantStatusCode:   206,
+			wantHeader:       http.Header{"Content-Range": {"bytes 18-19/20"}},
+			wantBody:         "ij",
+			wantAcceptRanges: true,
+		},
+		{
+			name:           "unsatisfiable range",
+			reqHeader:      http.Header{"Range": {"bytes=20-30"}},
+			resp:           httphandler.Response{StatusCode: 200, Body: []byte(body)},
+			wantStatusCode: 416,
+			wantHeader:     http.Header{"Content-Range": {"bytes */20"}},
+		},
+		{
+			name:             "malformed range is ignored",
+			reqHeader:        http.Header{"Range": {"bytes=5-2"}},
+			resp:             httphandler.Response{StatusCode: 200, Body: []byte(body)},
+			wantStatusCode:   200,
+			wantBody:         body,
+			wantAcceptRanges: true,
+		},
+		{
+			name:             "other units are ignored",
+			reqHeader:        http.Header{"Range": {"items=0-1"}},
+			resp:             httphandler.Response{StatusCode: 200, Body: []byte(body)},
+			wantStatusCode:   200,
+			wantBody:         body,
+			wantAcceptRanges: true,
+		},
+		{
+			name:             "too many ranges are ignored",
+			reqHeader:        http.Header{"Range": {"bytes=0-1,3-4,6-7"}},
+			resp:             httphandler.Response{StatusCode: 200, Body: []byte(body)},
+			maxRanges:        2,
+			wantStatusCode:   200,
+			wantBody:         body,
+			wantAcceptRanges: true,
+		},
+		{
+			name:             "overlapping ranges larger than the body are ignored",
+			reqHeader:        http.Header{"Range": {"bytes=0-,0-"}},
+			resp:             httphandler.Response{StatusCode: 200, Body: []byte(body)},
+			wantStatusCode:   200,
+			wantBody:         body,
+			wantAcceptRanges: true,
+		},
+		{
+			name:             "ranges only apply to GET",
+			method:           http.MethodPost,
+			reqHeader:        http.Header{"Range": {"bytes=0-1"}},
+			resp:             httphandler.Response{StatusCode: 200, Body: []byte(body)},
+			wantStatusCode:   200,
+			wantBody:         body,
+			wantAcceptRanges: true,
+		},
+		{
+			name:           "other status codes are left alone",
+			reqHeader:      http.Header{"Range": {"bytes=0-1"}},
+			resp:           httphandler.Response{StatusCode: 404, Body: []byte("not found")},
+			wantStatusCode: 404,
+			wantBody:       "not found",
+		},
+		{
+			name:             "If-Range matching the ETag",
+			reqHeader:        http.Header{"Range": {"bytes=0-1"}, "If-Range": {`"v1"`}},
+			resp:             httphandler.Response{StatusCode: 200, Header: http.Header{"Etag": {`"v1"`}}, Body: []byte(body)},
+			wantStatusCode:   206,
+			wantHeader:       http.Header{"Content-Range": {"bytes 0-1/20"}},
+			wantBody:         "01",
+			wantAcceptRanges: true,
+		},
+		{
+			name:             "If-Range not matching the ETag",
+			reqHeader:        http.Header{"Range": {"bytes=0-1"}, "If-Range": {`"v0"`}},
+			resp:             httphandler.Response{StatusCode: 200, Header: http.Header{"Etag": {`"v1"`}}, Body: []byte(body)},
+			wantStatusCode:   200,
+			wantBody:         body,
+			wantAcceptRanges: true,
+		},
+		{
+			name:             "If-Range with a weak ETag",
+			reqHeader:        http.Header{"Range": {"bytes=0-1"}, "If-Range": {`W/"v1"`}},
+			resp:             httphandler.Response{StatusCode: 200, Header: http.Header{"Etag": {`W/"v1"`}}, Body: []byte(body)},
+			wantStatusCode:   200,
+			wantBody:         body,
+			wantAcceptRanges: true,
+		},
+		{
+			name:             "If-Range matching Last-Modified",
+			reqHeader:        http.Header{"Range": {"bytes=0-1"}, "If-Range": {"Sat, 01 Jan 2000 00:00:00 GMT"}},
+			resp:             httphandler.Response{StatusCode: 200, Header: http.Header{"Last-Modified": {"Sat, 01 Jan 2000 00:00:00 GMT"}}, Body: []byte(body)},
+			wantStatusCode:   206,
+			wantHeader:       http.Header{"Content-Range": {"bytes 0-1/20"}},
+			wantBody:         "01",
+			wantAcceptRanges: true,
+		},
+		{
+			name:             "If-Range not matching Last-Modified",
+			reqHeader:        http.Header{"Range": {"bytes=0-1"}, "If-Range": {"Fri, 31 Dec 1999 00:00:00 GMT"}},
+			resp:             httphandler.Response{StatusCode: 200, Header: http.Header{"Last-Modified": {"Sat, 01 Jan 2000 00:00:00 GMT"}}, Body: []byte(body)},
+			wantStatusCode:   200,
+			wantBody:         body,
+			wantAcceptRanges: true,
+		},
+		{
+			name:             "seekable stream",
+			reqHeader:        http.Header{"Range": {"bytes=10-12"}},
+			resp:             httphandler.Response{StatusCode: 200, Stream: strings.NewReader(body)},
+			wantStatusCode:   206,
+			wantHeader:       http.Header{"Content-Range": {"bytes 10-12/20"}, "Content-Length": {"3"}},
+			wantBody:         "abc",
+			wantAcceptRanges: true,
+		},
+		{
+			name:           "other streams are left alone",
+			reqHeader:      http.Header{"Range": {"bytes=10-12"}},
+			resp:           httphandler.Response{StatusCode: 200, Stream: io.MultiReader(strings.NewReader(body))},
+			wantStatusCode: 200,
+			wantBody:       body,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			method := test.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			req := httptest.NewRequest(method, "/file", nil)
+			for name, values := range test.reqHeader {
+				req.Header[name] = values
+			}
+			sut := httphandler.Ranges{
+				Presenter: httphandler.PresenterFunc(func(*http.Request) httphandler.Response {
+					return test.resp
+				}),
+				MaxRanges: test.maxRanges,
+			}
+
+			gotResp := sut.PresentHTTP(req)
+
+			if got, want := gotResp.StatusCode, test.wantStatusCode; got != want {
+				t.Errorf("got status code %v, wanted %v", got, want)
+			}
+			for name, want := range test.wantHeader {
+				if got := gotResp.Header.Values(name); !reflect.DeepEqual(got, want) {
+					t.Errorf("got %s header %v, wanted %v", name, got, want)
+				}
+			}
+			if got := gotResp.Header.Get("Accept-Ranges") == "bytes"; got != test.wantAcceptRanges {
+				t.Errorf("got Accept-Ranges %v, wanted %v", got, test.wantAcceptRanges)
+			}
+			if got, want := readBody(t, gotResp), test.wantBody; got != want {
+				t.Errorf("got body: %s, wanted: %s", got, want)
+			}
+		})
+	}
+}
+
+// TestRangesMultipart tests that a request for several ranges gets a
+// multipart/byteranges response with a part for each range.
+func TestRangesMultipart(t *testing.T) {
+	const body = "0123456789abcdefghij"
+	for name, resp := range map[string]httphandler.Response{
+		"buffered": {Header: http.Header{"Content-Type": {"text/plain"}}, Body: []byte(body)},
+		"stream":   {Header: http.Header{"Content-Type": {"text/plain"}}, Stream: strings.NewReader(body)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			sut := httphandler.Ranges{
+				Presenter: httphandler.PresenterFunc(func(*http.Request) httphandler.Response {
+					return resp
+				}),
+			}
+			req := httptest.NewRequest(http.MethodGet, "/file", nil)
+			req.Header.Set("Range", "bytes=0-1, -2")
+
+			gotResp := sut.PresentHTTP(req)
+
+			if got, want := gotResp.StatusCode, 206; got != want {
+				t.Errorf("got status code %v, wanted %v", got, want)
+			}
+			mediaType, params, err := mime.ParseMediaType(gotResp.Header.Get("Content-Type"))
+			if err != nil || mediaType != "multipart/byteranges" {
+				t.Fatalf("got Content-Type %q, wanted multipart/byteranges", gotResp.Header.Get("Content-Type"))
+			}
+			type part struct {
+				contentType, contentRange, body string
+			}
+			var gotParts []part
+			mr := multipart.NewReader(strings.NewReader(readBody(t, gotResp)), params["boundary"])
+			for {
+				p, err := mr.NextPart()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("unexpected error reading part: %v", err)
+				}
+				b, _ := io.ReadAll(p)
+				gotParts = append(gotParts, part{p.Header.Get("Content-Type"), p.Header.Get("Content-Range"), string(b)})
+			}
+			wantParts := []part{
+				{"text/plain", "bytes 0-1/20", "01"},
+				{"text/plain", "bytes 18-19/20", "ij"},
+			}
+			if !reflect.DeepEqual(gotParts, wantParts) {
+				t.Errorf("got parts %v, wanted %v", gotParts, wantParts)
+			}
+		})
+	}
+}
+
+// readBody returns the body of resp whether it is buffered or
+// streamed.
+func readBody(t *testing.T, resp httphandler.Response) string {
+	t.Helper()
+	if resp.Stream == nil {
+		return string(resp.Body)
+	}
+	defer func() {
+		if c, ok := resp.Stream.(io.Closer); ok {
+			c.Close()
+		}
+	}()
+	b, err := io.ReadAll(resp.Stream)
+	if err != nil {
+		t.Fatalf("unexpected error reading stream: %v", err)
+	}
+	return string(b)
+}
@@ -0,0 +1,53 @@
+package httphandler
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+)
+
+// PanicError is the error reported when a Presenter panics.
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (p PanicError) Error() string {
+	return fmt.Sprintf("panic: %v\n%s", p.Value, p.Stack)
+}
+
+// Unwrap returns the value passed to panic if it was an error.
+func (p PanicError) Unwrap() error {
+	err, _ := p.Value.(error)
+	return err
+}
+
+// Recover is a Presenter which recovers from a panic in another
+// Presenter, passes it as a PanicError to HandleErr, and returns
+// Fallback instead. Leaving Fallback as the zero value means a
+// surrounding DefaultResp decides what the response will be.
+type Recover struct {
+	Presenter Presenter
+	HandleErr func(*http.Request, error)
+	Fallback  Response
+}
+
+// PresentHTTP returns the response from a Presenter or Fallback if
+// that Presenter panics. A panic with http.ErrAbortHandler is not
+// recovered since it is how a handler asks net/http to abort.
+func (rc Recover) PresentHTTP(r *http.Request) (resp Response) {
+	defer func() {
+		v := recover()
+		if v == nil {
+			return
+		}
+		if v == http.ErrAbortHandler {
+			panic(v)
+		}
+		if rc.HandleErr != nil {
+			rc.HandleErr(r, PanicError{Value: v, Stack: debug.Stack()})
+		}
+		resp = rc.Fallback
+	}()
+	return rc.Presenter.PresentHTTP(r)
+}
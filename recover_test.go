@@ -0,0 +1,107 @@
+package httphandler_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lag13/httphandler"
+)
+
+// TestRecover tests that Recover returns the fallback response and
+// reports the panic when the wrapped presenter panics.
+func TestRecover(t *testing.T) {
+	errBoom := errors.New("boom")
+	tests := []struct {
+		name             string
+		presenter        httphandler.Presenter
+		wantStatusCode   int
+		wantErrFnInvoked bool
+		wantPanicValue   interface{}
+	}{
+		{
+			name: "no panic",
+			presenter: httphandler.PresenterFunc(func(r *http.Request) httphandler.Response {
+				return httphandler.Response{StatusCode: 200}
+			}),
+			wantStatusCode:   200,
+			wantErrFnInvoked: false,
+		},
+		{
+			name: "panic with a string",
+			presenter: httphandler.PresenterFunc(func(r *http.Request) httphandler.Response {
+				panic("oh no")
+			}),
+			wantStatusCode:   500,
+			wantErrFnInvoked: true,
+			wantPanicValue:   "oh no",
+		},
+		{
+			name: "panic with an error",
+			presenter: httphandler.PresenterFunc(func(r *http.Request) httphandler.Response {
+				panic(errBoom)
+			}),
+			wantStatusCode:   500,
+			wantErrFnInvoked: true,
+			wantPanicValue:   errBoom,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fnErrHandler := fnToHandleErr{}
+			sut := httphandler.Recover{
+				Presenter: test.presenter,
+				HandleErr: fnErrHandler.handleError,
+				Fallback:  httphandler.Response{StatusCode: 500},
+			}
+			req := httptest.NewRequest(http.MethodGet, "/recover", nil)
+
+			gotResp := sut.PresentHTTP(req)
+
+			if got, want := gotResp.StatusCode, test.wantStatusCode; got != want {
+				t.Errorf("got status code %v, wanted %v", got, want)
+			}
+			if got, want := fnErrHandler.wasInvoked, test.wantErrFnInvoked; got != want {
+				t.Fatalf("error fn being invoked was %v", got)
+			}
+			if !test.wantErrFnInvoked {
+				return
+			}
+			if got, want := fnErrHandler.gotReq, req; got != want {
+				t.Errorf("got req: %#v, wanted %#v", got, want)
+			}
+			var panicErr httphandler.PanicError
+			if !errors.As(fnErrHandler.gotErr, &panicErr) {
+				t.Fatalf("got error %#v, wanted a PanicError", fnErrHandler.gotErr)
+			}
+			if got, want := panicErr.Value, test.wantPanicValue; got != want {
+				t.Errorf("got panic value %v, wanted %v", got, want)
+			}
+			if !strings.Contains(string(panicErr.Stack), "recover_test.go") {
+				t.Errorf("stack trace does not mention where the panic happened:\n%s", panicErr.Stack)
+			}
+			if err, ok := test.wantPanicValue.(error); ok && !errors.Is(fnErrHandler.gotErr, err) {
+				t.Errorf("the panic error does not wrap %v", err)
+			}
+		})
+	}
+}
+
+// TestRecoverAbortHandler tests that Recover does not swallow
+// http.ErrAbortHandler.
+func TestRecoverAbortHandler(t *testing.T) {
+	sut := httphandler.Recover{
+		Presenter: httphandler.PresenterFunc(func(r *http.Request) httphandler.Response {
+			panic(http.ErrAbortHandler)
+		}),
+	}
+	defer func() {
+		if got, want := recover(), http.ErrAbortHandler; got != want {
+			t.Errorf("got panic value %v, wanted %v", got, want)
+		}
+	}()
+
+	sut.PresentHTTP(httptest.NewRequest(http.MethodGet, "/recover", nil))
+}
@@ -0,0 +1,134 @@
+package httphandler
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Route pairs a path pattern with the Presenter which handles
+// requests on that path (typically a Dispatcher). The pattern is a
+// slash separated path where a segment like {id} captures that
+// segment of the request path and a final segment like {rest...}
+// captures the remainder of it. For example
+// /users/{id}/posts/{postID} or /static/{file...}.
+type Route struct {
+	Pattern   string
+	Presenter Presenter
+}
+
+// Router is a Presenter which dispatches to another Presenter based
+// on the request's path.
+type Router struct {
+	Routes       []Route
+	NotFoundPres Presenter
+	// MethodNotAllowedPres is optional. It is used by any
+	// Dispatcher handling a matched route (however it is wrapped)
+	// which has no MethodNotSupportedPres of its own.
+	MethodNotAllowedPres Presenter
+}
+
+// PresentHTTP finds the first Route whose pattern matches the request
+// path and hands the request to that route's Presenter. Captured path
+// parameters can be retrieved with PathParam. If no route matches
+// then NotFoundPres is used.
+func (rt Router) PresentHTTP(r *http.Request) Response {
+	segments := splitPath(r.URL.EscapedPath())
+	for _, route := range rt.Routes {
+		params, ok := matchPattern(splitPath(route.Pattern), segments)
+		if !ok {
+			continue
+		}
+		r = withPathParams(r, params)
+		if rt.MethodNotAllowedPres != nil {
+			r = r.WithContext(context.WithValue(r.Context(), methodNotAllowedKey{}, rt.MethodNotAllowedPres))
+		}
+		return route.Presenter.PresentHTTP(r)
+	}
+	return rt.NotFoundPres.PresentHTTP(r)
+}
+
+// pathParamsKey is the context key under which path parameters are
+// stored.
+type pathParamsKey struct{}
+
+// methodNotAllowedKey is the context key under which a Router stores
+// its MethodNotAllowedPres for Dispatchers to fall back on.
+type methodNotAllowedKey struct{}
+
+// PathParam returns the value of the named path parameter captured by
+// a Router or "" if there is no such parameter.
+func PathParam(r *http.Request, name string) string {
+	params, _ := r.Context().Value(pathParamsKey{}).(map[string]string)
+	return params[name]
+}
+
+// withPathParams returns a shallow copy of r with params added to the
+// ones (if any) which are already in its context.
+func withPathParams(r *http.Request, params map[string]string) *http.Request {
+	if len(params) == 0 {
+		return r
+	}
+	merged := map[string]string{}
+	if existing, ok := r.Context().Value(pathParamsKey{}).(map[string]string); ok {
+		for name, value := range existing {
+			merged[name] = value
+		}
+	}
+	for name, value := range params {
+		merged[name] = value
+	}
+	return r.WithContext(context.WithValue(r.Context(), pathParamsKey{}, merged))
+}
+
+// splitPath splits a path into its segments.
+func splitPath(path string) []string {
+	return strings.Split(strings.TrimPrefix(path, "/"), "/")
+}
+
+// matchPattern reports whether the escaped path segments match the
+// pattern segments and returns the captured (unescaped) parameters.
+func matchPattern(pattern, segments []string) (map[string]string, bool) {
+	params := map[string]string{}
+	for i, p := range pattern {
+		name, isParam := paramName(p)
+		if isParam && strings.HasSuffix(name, "...") && i == len(pattern)-1 {
+			if i >= len(segments) {
+				return nil, false
+			}
+			rest, err := url.PathUnescape(strings.Join(segments[i:], "/"))
+			if err != nil {
+				return nil, false
+			}
+			params[strings.TrimSuffix(name, "...")] = rest
+			return params, true
+		}
+		if i >= len(segments) {
+			return nil, false
+		}
+		segment, err := url.PathUnescape(segments[i])
+		if err != nil {
+			return nil, false
+		}
+		if !isParam {
+			if p != segment {
+				return nil, false
+			}
+			continue
+		}
+		if segment == "" {
+			return nil, false
+		}
+		params[name] = segment
+	}
+	return params, len(pattern) == len(segments)
+}
+
+// paramName returns the name within a {name} pattern segment.
+func paramName(segment string) (string, bool) {
+	if len(segment) < 3 || segment[0] != '{' || segment[len(segment)-1] != '}' {
+		return "", false
+	}
+	return segment[1 : len(segment)-1], true
+}
@@ -0,0 +1,241 @@
+package httphandler_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lag13/httphandler"
+)
+
+// TestRouter tests that the Router dispatches to the appropriate
+// presenter based off the request's path and makes any captured path
+// parameters available to that presenter.
+func TestRouter(t *testing.T) {
+	echoParams := func(names ...string) httphandler.Presenter {
+		return httphandler.PresenterFunc(func(r *http.Request) httphandler.Response {
+			body := r.Method
+			for _, name := range names {
+				body += fmt.Sprintf(" %s=%s", name, httphandler.PathParam(r, name))
+			}
+			return httphandler.Response{StatusCode: 200, Body: []byte(body)}
+		})
+	}
+	router := httphandler.Router{
+		Routes: []httphandler.Route{
+			{
+				Pattern: "/users",
+				Presenter: httphandler.Dispatcher{
+					MethodToPresenter: map[string]httphandler.Presenter{
+						http.MethodGet:  echoParams(),
+						http.MethodPost: echoParams(),
+					},
+				},
+			},
+			{
+				Pattern: "/users/me",
+				Presenter: httphandler.Dispatcher{
+					MethodToPresenter: map[string]httphandler.Presenter{
+						http.MethodGet: httphandler.PresenterFunc(func(r *http.Request) httphandler.Response {
+							return httphandler.Response{StatusCode: 200, Body: []byte("me")}
+						}),
+					},
+				},
+			},
+			{
+				Pattern: "/users/{id}/posts/{postID}",
+				Presenter: httphandler.Dispatcher{
+					MethodToPresenter: map[string]httphandler.Presenter{
+						http.MethodGet: echoParams("id", "postID"),
+					},
+				},
+			},
+			{
+				Pattern: "/orders",
+				Presenter: httphandler.Dispatcher{
+					MethodToPresenter: map[string]httphandler.Presenter{
+						http.MethodGet: echoParams(),
+					},
+					MethodNotSupportedPres: httphandler.PresenterFunc(func(r *http.Request) httphandler.Response {
+						return httphandler.Response{StatusCode: http.StatusMethodNotAllowed, Body: []byte("orders are read only")}
+					}),
+				},
+			},
+			{
+				Pattern: "/carts",
+				Presenter: &httphandler.Dispatcher{
+					MethodToPresenter: map[string]httphandler.Presenter{
+						http.MethodGet: echoParams(),
+					},
+				},
+			},
+			{
+				Pattern: "/invoices",
+				Presenter: httphandler.Recover{
+					Presenter: httphandler.Dispatcher{
+						MethodToPresenter: map[string]httphandler.Presenter{
+							http.MethodGet: echoParams(),
+						},
+					},
+				},
+			},
+			{
+				Pattern: "/health",
+				Presenter: httphandler.PresenterFunc(func(r *http.Request) httphandler.Response {
+					return httphandler.Response{StatusCode: 200, Body: []byte("ok " + r.Method)}
+				}),
+			},
+			{
+				Pattern: "/static/{file...}",
+				Presenter: httphandler.Dispatcher{
+					MethodToPresenter: map[string]httphandler.Presenter{
+						http.MethodGet: echoParams("file"),
+					},
+				},
+			},
+		},
+		NotFoundPres: httphandler.PresenterFunc(func(r *http.Request) httphandler.Response {
+			return httphandler.Response{StatusCode: http.StatusNotFound, Body: []byte("not found")}
+		}),
+		MethodNotAllowedPres: httphandler.PresenterFunc(func(r *http.Request) httphandler.Response {
+			return httphandler.Response{StatusCode: http.StatusMethodNotAllowed, Body: []byte("not allowed")}
+		}),
+	}
+	tests := []struct {
+		name           string
+		request        *http.Request
+		wantStatusCode int
+		wantBody       string
+	}{
+		{
+			name:           "matches a literal path",
+			request:        httptest.NewRequest(http.MethodPost, "/users", nil),
+			wantStatusCode: 200,
+			wantBody:       "POST",
+		},
+		{
+			name:           "earlier routes take precedence",
+			request:        httptest.NewRequest(http.MethodGet, "/users/me", nil),
+			wantStatusCode: 200,
+			wantBody:       "me",
+		},
+		{
+			name:           "captures path parameters",
+			request:        httptest.NewRequest(http.MethodGet, "/users/42/posts/7", nil),
+			wantStatusCode: 200,
+			wantBody:       "GET id=42 postID=7",
+		},
+		{
+			name:           "path parameters are unescaped",
+			request:        httptest.NewRequest(http.MethodGet, "/users/a%2Fb/posts/c%20d", nil),
+			wantStatusCode: 200,
+			wantBody:       "GET id=a/b postID=c d",
+		},
+		{
+			name:           "captures the rest of the path",
+			request:        httptest.NewRequest(http.MethodGet, "/static/css/site.css", nil),
+			wantStatusCode: 200,
+			wantBody:       "GET file=css/site.css",
+		},
+		{
+			name:           "empty segments do not match parameters",
+			request:        httptest.NewRequest(http.MethodGet, "/users//posts/7", nil),
+			wantStatusCode: http.StatusNotFound,
+			wantBody:       "not found",
+		},
+		{
+			name:           "too many segments",
+			request:        httptest.NewRequest(http.MethodGet, "/users/42/posts/7/comments", nil),
+			wantStatusCode: http.StatusNotFound,
+			wantBody:       "not found",
+		},
+		{
+			name:           "a route's own Dispatcher handles unsupported methods",
+			request:        httptest.NewRequest(http.MethodPost, "/orders", nil),
+			wantStatusCode: http.StatusMethodNotAllowed,
+			wantBody:       "orders are read only",
+		},
+		{
+			name:           "routes can use any Presenter",
+			request:        httptest.NewRequest(http.MethodPut, "/health", nil),
+			wantStatusCode: 200,
+			wantBody:       "ok PUT",
+		},
+		{
+			name:           "unsupported method on a matching path",
+			request:        httptest.NewRequest(http.MethodDelete, "/users/42/posts/7", nil),
+			wantStatusCode: http.StatusMethodNotAllowed,
+			wantBody:       "not allowed",
+		},
+		{
+			name:           "unsupported method on a Dispatcher pointer",
+			request:        httptest.NewRequest(http.MethodDelete, "/carts", nil),
+			wantStatusCode: http.StatusMethodNotAllowed,
+			wantBody:       "not allowed",
+		},
+		{
+			name:           "unsupported method on a wrapped Dispatcher",
+			request:        httptest.NewRequest(http.MethodDelete, "/invoices", nil),
+			wantStatusCode: http.StatusMethodNotAllowed,
+			wantBody:       "not allowed",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gotResp := router.PresentHTTP(test.request)
+
+			if got, want := gotResp.StatusCode, test.wantStatusCode; got != want {
+				t.Errorf("got status code %v, wanted %v", got, want)
+			}
+			if got, want := string(gotResp.Body), test.wantBody; got != want {
+				t.Errorf("got body: %s, wanted: %s", got, want)
+			}
+		})
+	}
+}
+
+// TestPathParamNested tests that path parameters captured by nested
+// Routers accumulate rather than replace each other.
+func TestPathParamNested(t *testing.T) {
+	inner := httphandler.Router{
+		Routes: []httphandler.Route{
+			{
+				Pattern: "/posts/{postID}",
+				Presenter: httphandler.Dispatcher{
+					MethodToPresenter: map[string]httphandler.Presenter{
+						http.MethodGet: httphandler.PresenterFunc(func(r *http.Request) httphandler.Response {
+							body := fmt.Sprintf("%s %s", httphandler.PathParam(r, "id"), httphandler.PathParam(r, "postID"))
+							return httphandler.Response{Body: []byte(body)}
+						}),
+					},
+				},
+			},
+		},
+	}
+	outer := httphandler.Router{
+		Routes: []httphandler.Route{
+			{
+				Pattern: "/users/{id}/{rest...}",
+				Presenter: httphandler.Dispatcher{
+					MethodToPresenter: map[string]httphandler.Presenter{
+						http.MethodGet: httphandler.PresenterFunc(func(r *http.Request) httphandler.Response {
+							u := *r.URL
+							u.Path = "/" + httphandler.PathParam(r, "rest")
+							u.RawPath = ""
+							r2 := *r
+							r2.URL = &u
+							return inner.PresentHTTP(&r2)
+						}),
+					},
+				},
+			},
+		},
+	}
+
+	gotResp := outer.PresentHTTP(httptest.NewRequest(http.MethodGet, "/users/1/posts/2", nil))
+
+	if got, want := string(gotResp.Body), "1 2"; got != want {
+		t.Errorf("got body: %s, wanted: %s", got, want)
+	}
+}
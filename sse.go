@@ -0,0 +1,120 @@
+package httphandler
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event is a server-sent event. Empty fields are left out.
+type Event struct {
+	ID    string
+	Event string
+	Data  string
+	Retry time.Duration
+}
+
+// SSE is an ErrPresenter which streams server-sent events (the
+// text/event-stream format) to the client. The stream ends once the
+// channel of events is closed or the client goes away.
+type SSE struct {
+	// Events returns the events to send. lastEventID is the
+	// request's Last-Event-ID header (which a reconnecting client
+	// sends) so that the stream can pick up where it left off. The
+	// request's context is cancelled once the stream is over so
+	// whatever is sending the events knows to stop.
+	Events func(r *http.Request, lastEventID string) (<-chan Event, error)
+	// Heartbeat is how often a comment is sent when there are no
+	// events so that proxies do not drop the idle connection. Zero
+	// means no heartbeats.
+	Heartbeat time.Duration
+}
+
+// ErrPresentHTTP returns a response which streams the events. The
+// error from Events is returned along with the zero Response.
+func (s SSE) ErrPresentHTTP(r *http.Request) (Response, error) {
+	ctx, cancel := context.WithCancel(r.Context())
+	events, err := s.Events(r.WithContext(ctx), r.Header.Get("Last-Event-ID"))
+	if err != nil {
+		cancel()
+		return Response{}, err
+	}
+	stream := &eventStream{ctx: ctx, cancel: cancel, events: events}
+	if s.Heartbeat > 0 {
+		stream.heartbeat = time.NewTicker(s.Heartbeat)
+	}
+	return Response{
+		StatusCode: http.StatusOK,
+		Header: http.Header{
+			"Content-Type":  {"text/event-stream"},
+			"Cache-Control": {"no-cache"},
+		},
+		Stream: stream,
+	}, nil
+}
+
+// eventStream is an io.ReadCloser which encodes events as they come
+// in.
+type eventStream struct {
+	ctx       context.Context
+	cancel    context.CancelFunc
+	events    <-chan Event
+	heartbeat *time.Ticker
+	buf       bytes.Buffer
+}
+
+// Read blocks until there is an event or heartbeat to read. It
+// returns io.EOF once there are no more events or the client is gone.
+func (s *eventStream) Read(p []byte) (int, error) {
+	var ticks <-chan time.Time
+	if s.heartbeat != nil {
+		ticks = s.heartbeat.C
+	}
+	for s.buf.Len() == 0 {
+		select {
+		case event, ok := <-s.events:
+			if !ok {
+				return 0, io.EOF
+			}
+			writeEvent(&s.buf, event)
+		case <-ticks:
+			s.buf.WriteString(":\n\n")
+		case <-s.ctx.Done():
+			return 0, io.EOF
+		}
+	}
+	return s.buf.Read(p)
+}
+
+// Close stops the heartbeats and cancels the context of the events.
+func (s *eventStream) Close() error {
+	if s.heartbeat != nil {
+		s.heartbeat.Stop()
+	}
+	s.cancel()
+	return nil
+}
+
+// writeEvent writes an event in the text/event-stream format.
+func writeEvent(buf *bytes.Buffer, event Event) {
+	// Field values other than data cannot span lines.
+	singleLine := strings.NewReplacer("\r", "", "\n", "")
+	if event.ID != "" {
+		buf.WriteString("id: " + singleLine.Replace(event.ID) + "\n")
+	}
+	if event.Event != "" {
+		buf.WriteString("event: " + singleLine.Replace(event.Event) + "\n")
+	}
+	if event.Retry > 0 {
+		buf.WriteString("retry: " + strconv.FormatInt(event.Retry.Milliseconds(), 10) + "\n")
+	}
+	data := strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(event.Data)
+	for _, line := range strings.Split(data, "\n") {
+		buf.WriteString("data: " + line + "\n")
+	}
+	buf.WriteString("\n")
+}
@@ -0,0 +1,112 @@
+package httphandler_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lag13/httphandler"
+)
+
+// TestSSE tests that events are written in the text/event-stream
+// format and that the stream can be resumed.
+func TestSSE(t *testing.T) {
+	var gotLastEventID string
+	sut := httphandler.Writer{
+		Presenter: httphandler.ErrHandler{
+			ErrPresenter: httphandler.SSE{
+				Events: func(r *http.Request, lastEventID string) (<-chan httphandler.Event, error) {
+					gotLastEventID = lastEventID
+					events := make(chan httphandler.Event, 3)
+					events <- httphandler.Event{ID: "4", Event: "status", Data: "up"}
+					events <- httphandler.Event{Data: "line one\nline two\r\nline three"}
+					events <- httphandler.Event{ID: "6\n", Retry: 1500 * time.Millisecond}
+					close(events)
+					return events, nil
+				},
+			},
+			HandleErr: func(r *http.Request, err error) {
+				t.Errorf("got unexpected error: %v", err)
+			},
+		},
+	}
+	req := httptest.NewRequest(http.MethodGet, "/events", nil)
+	req.Header.Set("Last-Event-ID", "3")
+	w := httptest.NewRecorder()
+
+	sut.ServeHTTP(w, req)
+
+	if got, want := gotLastEventID, "3"; got != want {
+		t.Errorf("got last event id %s, wanted %s", got, want)
+	}
+	if got, want := w.Header().Get("Content-Type"), "text/event-stream"; got != want {
+		t.Errorf("got content type %s, wanted %s", got, want)
+	}
+	wantBody := "id: 4\nevent: status\ndata: up\n\n" +
+		"data: line one\ndata: line two\ndata: line three\n\n" +
+		"id: 6\nretry: 1500\ndata: \n\n"
+	if got := w.Body.String(); got != wantBody {
+		t.Errorf("got body: %q, wanted: %q", got, wantBody)
+	}
+	if !w.Flushed {
+		t.Errorf("the events were not flushed")
+	}
+}
+
+// TestSSEDisconnect tests that heartbeats are sent while there are no
+// events and that the stream ends, and tells the event source to stop,
+// when the client goes away.
+func TestSSEDisconnect(t *testing.T) {
+	stopped := make(chan struct{})
+	sut := httphandler.Writer{
+		Presenter: httphandler.ErrHandler{
+			ErrPresenter: httphandler.SSE{
+				Events: func(r *http.Request, lastEventID string) (<-chan httphandler.Event, error) {
+					go func() {
+						<-r.Context().Done()
+						close(stopped)
+					}()
+					return make(chan httphandler.Event), nil
+				},
+				Heartbeat: time.Millisecond,
+			},
+		},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	w := httptest.NewRecorder()
+
+	sut.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/events", nil).WithContext(ctx))
+
+	if got := w.Body.String(); !strings.HasPrefix(got, ":\n\n") {
+		t.Errorf("got body: %q, wanted heartbeats", got)
+	}
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Errorf("the event source was never told to stop")
+	}
+}
+
+// TestSSEFails tests that an error from Events is returned.
+func TestSSEFails(t *testing.T) {
+	sut := httphandler.SSE{
+		Events: func(r *http.Request, lastEventID string) (<-chan httphandler.Event, error) {
+			return nil, errors.New("cannot subscribe")
+		},
+	}
+
+	gotResp, gotErr := sut.ErrPresentHTTP(httptest.NewRequest(http.MethodGet, "/events", nil))
+
+	if got, want := fmt.Sprintf("%v", gotErr), "cannot subscribe"; got != want {
+		t.Errorf("got error msg: %s, wanted error msg: %s", got, want)
+	}
+	if gotResp.Stream != nil {
+		t.Errorf("got a stream, wanted the zero response")
+	}
+}
@@ -0,0 +1,143 @@
+package httphandler
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"path"
+	"sync"
+)
+
+// Templates is a set of html/template pages which share layouts. Each
+// file matching the Pages pattern is parsed together with all the
+// files matching the Layouts patterns into its own template (so pages
+// can define the same blocks without clashing) and is referred to by
+// its base name. A layout is typically inherited by having the layout
+// file define a template which renders blocks like {{block "content"
+// .}}{{end}} and having each page call that template and define those
+// blocks:
+//
+//	{{template "base" .}}
+//	{{define "content"}}Hello {{.Name}}{{end}}
+//
+// The templates are parsed the first time they are needed and then
+// reused unless Dev is set in which case they are parsed again every
+// time so that changes on disk (with an FS like os.DirFS) show up
+// without restarting. Templates must not be copied after first use.
+type Templates struct {
+	FS fs.FS
+	// Layouts are patterns (as understood by fs.Glob) for files
+	// which every page is parsed with such as base layouts and
+	// partials.
+	Layouts []string
+	// Pages is a pattern for the page files.
+	Pages string
+	Funcs template.FuncMap
+	Dev   bool
+
+	mu    sync.Mutex
+	pages map[string]*template.Template
+	err   error
+}
+
+// Lookup returns the template of the named page.
+func (t *Templates) Lookup(name string) (*template.Template, error) {
+	var pages map[string]*template.Template
+	var err error
+	if t.Dev {
+		pages, err = t.parse()
+	} else {
+		t.mu.Lock()
+		if t.pages == nil && t.err == nil {
+			t.pages, t.err = t.parse()
+		}
+		pages, err = t.pages, t.err
+		t.mu.Unlock()
+	}
+	if err != nil {
+		return nil, err
+	}
+	page, ok := pages[name]
+	if !ok {
+		return nil, fmt.Errorf("template page %q not found", name)
+	}
+	return page, nil
+}
+
+// parse parses every page along with the layouts.
+func (t *Templates) parse() (map[string]*template.Template, error) {
+	layouts := template.New("").Funcs(t.Funcs)
+	for _, pattern := range t.Layouts {
+		if _, err := layouts.ParseFS(t.FS, pattern); err != nil {
+			return nil, fmt.Errorf("parsing template layouts: %w", err)
+		}
+	}
+	names, err := fs.Glob(t.FS, t.Pages)
+	if err != nil {
+		return nil, fmt.Errorf("parsing template pages: %w", err)
+	}
+	pages := map[string]*template.Template{}
+	for _, name := range names {
+		base := path.Base(name)
+		if _, ok := pages[base]; ok {
+			return nil, fmt.Errorf("parsing template pages: more than one page is called %q", base)
+		}
+		content, err := fs.ReadFile(t.FS, name)
+		if err != nil {
+			return nil, fmt.Errorf("parsing template pages: %w", err)
+		}
+		page, err := layouts.Clone()
+		if err != nil {
+			return nil, fmt.Errorf("parsing template page %s: %w", name, err)
+		}
+		if _, err := page.New(base).Parse(string(content)); err != nil {
+			return nil, fmt.Errorf("parsing template page %s: %w", name, err)
+		}
+		pages[base] = page
+	}
+	return pages, nil
+}
+
+// Template is an ErrPresenter which renders a page of Templates with
+// the data returned by Data.
+type Template struct {
+	Templates *Templates
+	Name      string
+	Data      func(*http.Request) (interface{}, error)
+	// StatusCode is the status code of a successful response. It
+	// defaults to 200.
+	StatusCode int
+}
+
+// ErrPresentHTTP returns a response with the rendered page. The page
+// is rendered into a buffer first so an error from Data, from parsing
+// the templates or from executing the page produces the zero Response
+// along with that error (so DefaultResp can supply the response).
+func (t Template) ErrPresentHTTP(r *http.Request) (Response, error) {
+	var data interface{}
+	if t.Data != nil {
+		var err error
+		if data, err = t.Data(r); err != nil {
+			return Response{}, err
+		}
+	}
+	page, err := t.Templates.Lookup(t.Name)
+	if err != nil {
+		return Response{}, err
+	}
+	var buf bytes.Buffer
+	if err := page.ExecuteTemplate(&buf, t.Name, data); err != nil {
+		return Response{}, fmt.Errorf("executing template page %q: %w", t.Name, err)
+	}
+	statusCode := t.StatusCode
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+	return Response{
+		StatusCode: statusCode,
+		Header:     http.Header{"Content-Type": {"text/html; charset=utf-8"}},
+		Body:       buf.Bytes(),
+	}, nil
+}
@@ -0,0 +1,164 @@
+package httphandler_test
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/lag13/httphandler"
+)
+
+// templateFS returns the files of a small site with a layout, a
+// partial and a few pages.
+func templateFS() fstest.MapFS {
+	return fstest.MapFS{
+		"layouts/base.html": {Data: []byte(`{{define "base"}}<title>{{block "title" .}}Site{{end}}</title>{{template "nav"}}{{block "content" .}}{{end}}{{end}}`)},
+		"layouts/nav.html":  {Data: []byte(`{{define "nav"}}<nav>{{upper "menu"}}</nav>{{end}}`)},
+		"pages/home.html":   {Data: []byte(`{{template "base" .}}{{define "content"}}<p>Hello {{.}}</p>{{end}}`)},
+		"pages/about.html":  {Data: []byte(`{{template "base" .}}{{define "title"}}About{{end}}{{define "content"}}<p>About</p>{{end}}`)},
+		"pages/broken.html": {Data: []byte(`{{template "base" .}}{{define "content"}}{{fail}}{{end}}`)},
+	}
+}
+
+// templateFuncs are the functions used by templateFS.
+var templateFuncs = template.FuncMap{
+	"upper": strings.ToUpper,
+	"fail":  func() (string, error) { return "", errors.New("failed") },
+}
+
+// TestTemplate tests that Template renders pages with their layouts
+// and returns errors from Data, looking up pages and executing them.
+func TestTemplate(t *testing.T) {
+	templates := &httphandler.Templates{
+		FS:      templateFS(),
+		Layouts: []string{"layouts/*.html"},
+		Pages:   "pages/*.html",
+		Funcs:   templateFuncs,
+	}
+	dataErr := errors.New("no data")
+	tests := []struct {
+		name           string
+		template       httphandler.Template
+		wantStatusCode int
+		wantBody       string
+		wantErr        bool
+	}{
+		{
+			name: "renders a page with its layout",
+			template: httphandler.Template{
+				Templates: templates,
+				Name:      "home.html",
+				Data:      func(*http.Request) (interface{}, error) { return "<world>", nil },
+			},
+			wantStatusCode: 200,
+			wantBody:       "<title>Site</title><nav>MENU</nav><p>Hello &lt;world&gt;</p>",
+		},
+		{
+			name: "pages override blocks independently",
+			template: httphandler.Template{
+				Templates:  templates,
+				Name:       "about.html",
+				StatusCode: 202,
+			},
+			wantStatusCode: 202,
+			wantBody:       "<title>About</title><nav>MENU</nav><p>About</p>",
+		},
+		{
+			name: "data error",
+			template: httphandler.Template{
+				Templates: templates,
+				Name:      "home.html",
+				Data:      func(*http.Request) (interface{}, error) { return nil, dataErr },
+			},
+			wantErr: true,
+		},
+		{
+			name:     "missing page",
+			template: httphandler.Template{Templates: templates, Name: "missing.html"},
+			wantErr:  true,
+		},
+		{
+			name:     "execution error",
+			template: httphandler.Template{Templates: templates, Name: "broken.html"},
+			wantErr:  true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gotResp, err := test.template.ErrPresentHTTP(httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if test.wantErr {
+				if err == nil {
+					t.Error("got nil error, wanted non-nil")
+				}
+				if got, want := gotResp.StatusCode, 0; got != want {
+					t.Errorf("got status code %v, wanted %v", got, want)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got, want := gotResp.StatusCode, test.wantStatusCode; got != want {
+				t.Errorf("got status code %v, wanted %v", got, want)
+			}
+			if got, want := gotResp.Header.Get("Content-Type"), "text/html; charset=utf-8"; got != want {
+				t.Errorf("got Content-Type %s, wanted %s", got, want)
+			}
+			if got, want := string(gotResp.Body), test.wantBody; got != want {
+				t.Errorf("got body: %s, wanted: %s", got, want)
+			}
+		})
+	}
+}
+
+// TestTemplatesDev tests that templates are only parsed once unless
+// Dev is set in which case changes to them show up straight away.
+func TestTemplatesDev(t *testing.T) {
+	for _, dev := range []bool{false, true} {
+		fsys := templateFS()
+		sut := httphandler.Template{
+			Templates: &httphandler.Templates{
+				FS:      fsys,
+				Layouts: []string{"layouts/*.html"},
+				Pages:   "pages/*.html",
+				Funcs:   templateFuncs,
+				Dev:     dev,
+			},
+			Name: "about.html",
+		}
+		if _, err := sut.ErrPresentHTTP(httptest.NewRequest(http.MethodGet, "/", nil)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		fsys["pages/about.html"] = &fstest.MapFile{Data: []byte(`changed`)}
+
+		gotResp, err := sut.ErrPresentHTTP(httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := "<title>About</title><nav>MENU</nav><p>About</p>"
+		if dev {
+			want = "changed"
+		}
+		if got := string(gotResp.Body); got != want {
+			t.Errorf("dev=%v: got body: %s, wanted: %s", dev, got, want)
+		}
+	}
+}
+
+// TestTemplatesParseError tests that errors parsing the templates are
+// returned.
+func TestTemplatesParseError(t *testing.T) {
+	fsys := templateFS()
+	fsys["pages/bad.html"] = &fstest.MapFile{Data: []byte(`{{if}}`)}
+	templates := &httphandler.Templates{FS: fsys, Layouts: []string{"layouts/*.html"}, Pages: "pages/*.html", Funcs: templateFuncs}
+
+	if _, err := templates.Lookup("home.html"); err == nil {
+		t.Error("got nil error, wanted non-nil")
+	}
+}
@@ -0,0 +1,124 @@
+package httphandler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime/debug"
+	"time"
+)
+
+// AbandonedError is reported by Timeout when it stops waiting on a
+// Presenter and then again, with Finished set, if that Presenter ever
+// returns. An abandoned Presenter which is never reported as finished
+// has probably leaked.
+type AbandonedError struct {
+	// Err is why the Presenter was abandoned, either
+	// context.DeadlineExceeded or context.Canceled.
+	Err      error
+	Elapsed  time.Duration
+	Finished bool
+}
+
+func (a AbandonedError) Error() string {
+	if a.Finished {
+		return fmt.Sprintf("abandoned presenter finished after %v", a.Elapsed)
+	}
+	return fmt.Sprintf("presenter abandoned after %v: %v", a.Elapsed, a.Err)
+}
+
+// Unwrap returns why the Presenter was abandoned.
+func (a AbandonedError) Unwrap() error {
+	return a.Err
+}
+
+// Timeout is a Presenter which gives another Presenter a limited
+// amount of time to produce a response. The request passed to that
+// Presenter has a context with a deadline so it knows when to give up.
+// Leaving Fallback as the zero value means a surrounding DefaultResp
+// decides what the response will be. The deadline also applies to
+// reading a streaming body.
+type Timeout struct {
+	Presenter Presenter
+	Duration  time.Duration
+	Fallback  Response
+	HandleErr func(*http.Request, error)
+}
+
+// timeoutResult is what a Presenter run by Timeout produced.
+type timeoutResult struct {
+	resp     Response
+	panicked bool
+	panicVal interface{}
+	stack    []byte
+}
+
+// PresentHTTP returns the response from a Presenter or Fallback if it
+// takes longer than Duration (or the request is cancelled first). The
+// abandoned call is reported to HandleErr as an AbandonedError. A
+// panic in the Presenter is passed on if it happens in time and
+// reported to HandleErr as a PanicError otherwise.
+func (t Timeout) PresentHTTP(r *http.Request) Response {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(r.Context(), t.Duration)
+	r = r.WithContext(ctx)
+	results := make(chan timeoutResult, 1)
+	go func() {
+		var res timeoutResult
+		defer func() {
+			if v := recover(); v != nil {
+				// Whether the panic gets passed on or reported
+				// depends on whether anyone is still waiting which
+				// only PresentHTTP knows.
+				res = timeoutResult{panicked: true, panicVal: v, stack: debug.Stack()}
+			}
+			results <- res
+		}()
+		res.resp = t.Presenter.PresentHTTP(r)
+	}()
+	select {
+	case res := <-results:
+		if res.panicked {
+			cancel()
+			panic(res.panicVal)
+		}
+		if res.resp.Stream == nil {
+			cancel()
+			return res.resp
+		}
+		// The stream might still need the context.
+		res.resp.Stream = cancelOnClose{Reader: res.resp.Stream, cancel: cancel}
+		return res.resp
+	case <-ctx.Done():
+	}
+	cancel()
+	err := ctx.Err()
+	if t.HandleErr != nil {
+		t.HandleErr(r, AbandonedError{Err: err, Elapsed: time.Since(start)})
+	}
+	go func() {
+		res := <-results
+		// No one is going to read the response.
+		closeStream(res.resp)
+		if t.HandleErr == nil {
+			return
+		}
+		if res.panicked {
+			t.HandleErr(r, PanicError{Value: res.panicVal, Stack: res.stack})
+		}
+		t.HandleErr(r, AbandonedError{Err: err, Elapsed: time.Since(start), Finished: true})
+	}()
+	return t.Fallback
+}
+
+// cancelOnClose cancels a context once the stream is closed.
+type cancelOnClose struct {
+	io.Reader
+	cancel context.CancelFunc
+}
+
+func (c cancelOnClose) Close() error {
+	defer c.cancel()
+	return closeStream(Response{Stream: c.Reader})
+}
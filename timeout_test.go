@@ -0,0 +1,159 @@
+package httphandler_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lag13/httphandler"
+)
+
+// TestTimeoutInTime tests that a presenter which finishes in time has
+// its response returned and got a deadline on its request.
+func TestTimeoutInTime(t *testing.T) {
+	sut := httphandler.Timeout{
+		Presenter: httphandler.PresenterFunc(func(r *http.Request) httphandler.Response {
+			if _, ok := r.Context().Deadline(); !ok {
+				t.Errorf("the request has no deadline")
+			}
+			return httphandler.Response{StatusCode: 200, Body: []byte("fast")}
+		}),
+		Duration: time.Second,
+		Fallback: httphandler.Response{StatusCode: http.StatusGatewayTimeout},
+		HandleErr: func(r *http.Request, err error) {
+			t.Errorf("got unexpected error: %v", err)
+		},
+	}
+
+	gotResp := sut.PresentHTTP(httptest.NewRequest(http.MethodGet, "/timeout", nil))
+
+	if got, want := string(gotResp.Body), "fast"; got != want {
+		t.Errorf("got body: %s, wanted: %s", got, want)
+	}
+}
+
+// TestTimeoutAbandoned tests that a slow presenter is abandoned, the
+// fallback response is returned, and both the abandonment and the
+// eventual end of the presenter are reported.
+func TestTimeoutAbandoned(t *testing.T) {
+	release := make(chan struct{})
+	errs := make(chan error, 2)
+	sut := httphandler.Timeout{
+		Presenter: httphandler.PresenterFunc(func(r *http.Request) httphandler.Response {
+			<-r.Context().Done()
+			<-release
+			return httphandler.Response{StatusCode: 200, Body: []byte("slow")}
+		}),
+		Duration: 10 * time.Millisecond,
+		Fallback: httphandler.Response{StatusCode: http.StatusGatewayTimeout},
+		HandleErr: func(r *http.Request, err error) {
+			errs <- err
+		},
+	}
+
+	gotResp := sut.PresentHTTP(httptest.NewRequest(http.MethodGet, "/timeout", nil))
+
+	if got, want := gotResp.StatusCode, http.StatusGatewayTimeout; got != want {
+		t.Errorf("got status code %v, wanted %v", got, want)
+	}
+	var abandoned httphandler.AbandonedError
+	if err := <-errs; !errors.As(err, &abandoned) || abandoned.Finished || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got error %#v, wanted an unfinished AbandonedError because of the deadline", err)
+	}
+	close(release)
+	if err := <-errs; !errors.As(err, &abandoned) || !abandoned.Finished {
+		t.Errorf("got error %#v, wanted a finished AbandonedError", err)
+	}
+}
+
+// TestTimeoutCancelled tests that the presenter is abandoned when the
+// request is cancelled.
+func TestTimeoutCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var gotErr error
+	sut := httphandler.Timeout{
+		Presenter: httphandler.PresenterFunc(func(r *http.Request) httphandler.Response {
+			cancel()
+			<-r.Context().Done()
+			return httphandler.Response{StatusCode: 200}
+		}),
+		Duration: time.Hour,
+		HandleErr: func(r *http.Request, err error) {
+			if gotErr == nil {
+				gotErr = err
+			}
+		},
+	}
+
+	gotResp := sut.PresentHTTP(httptest.NewRequest(http.MethodGet, "/timeout", nil).WithContext(ctx))
+
+	if got, want := gotResp.StatusCode, 0; got != want {
+		t.Errorf("got status code %v, wanted %v", got, want)
+	}
+	if !errors.Is(gotErr, context.Canceled) {
+		t.Errorf("got error %v, wanted it to be because of cancellation", gotErr)
+	}
+}
+
+// TestTimeoutPanics tests that a panic in the presenter is passed on
+// if it happens in time and is reported exactly once otherwise.
+func TestTimeoutPanics(t *testing.T) {
+	t.Run("in time", func(t *testing.T) {
+		sut := httphandler.Timeout{
+			Presenter: httphandler.PresenterFunc(func(r *http.Request) httphandler.Response {
+				panic("boom")
+			}),
+			Duration: time.Hour,
+			HandleErr: func(r *http.Request, err error) {
+				t.Errorf("got unexpected error: %v", err)
+			},
+		}
+		defer func() {
+			if got, want := recover(), "boom"; got != want {
+				t.Errorf("got panic %v, wanted %v", got, want)
+			}
+		}()
+
+		sut.PresentHTTP(httptest.NewRequest(http.MethodGet, "/timeout", nil))
+
+		t.Error("wanted a panic")
+	})
+	t.Run("after being abandoned", func(t *testing.T) {
+		release := make(chan struct{})
+		errs := make(chan error, 3)
+		sut := httphandler.Timeout{
+			Presenter: httphandler.PresenterFunc(func(r *http.Request) httphandler.Response {
+				<-r.Context().Done()
+				<-release
+				panic("boom")
+			}),
+			Duration: 10 * time.Millisecond,
+			HandleErr: func(r *http.Request, err error) {
+				errs <- err
+			},
+		}
+
+		sut.PresentHTTP(httptest.NewRequest(http.MethodGet, "/timeout", nil))
+		close(release)
+
+		var abandoned httphandler.AbandonedError
+		if err := <-errs; !errors.As(err, &abandoned) || abandoned.Finished {
+			t.Errorf("got error %#v, wanted an unfinished AbandonedError", err)
+		}
+		var panicErr httphandler.PanicError
+		if err := <-errs; !errors.As(err, &panicErr) || panicErr.Value != "boom" {
+			t.Errorf("got error %#v, wanted a PanicError", err)
+		}
+		if err := <-errs; !errors.As(err, &abandoned) || !abandoned.Finished {
+			t.Errorf("got error %#v, wanted a finished AbandonedError", err)
+		}
+		select {
+		case err := <-errs:
+			t.Errorf("got unexpected error: %v", err)
+		default:
+		}
+	})
+}
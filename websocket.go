@@ -0,0 +1,400 @@
+package httphandler
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Message types of a WebSocketConn.
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+)
+
+// Opcodes of the frames which are not messages.
+const (
+	continuationFrame = 0
+	closeFrame        = 8
+	pingFrame         = 9
+	pongFrame         = 10
+)
+
+// Close codes from RFC 6455 section 7.4.1.
+const (
+	closeNormal         = 1000
+	closeProtocolError  = 1002
+	closeNoStatus       = 1005
+	closeInvalidPayload = 1007
+	closeMessageTooBig  = 1009
+)
+
+// defaultReadLimit is the default WebSocket.ReadLimit.
+const defaultReadLimit = 1 << 20
+
+// websocketGUID is appended to the client's key to compute the
+// Sec-WebSocket-Accept header.
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocket is an ErrPresenter which upgrades the connection to a
+// WebSocket (RFC 6455) and hands it to Handle. It is meant to be
+// registered under GET in a Dispatcher. Requests which are not a valid
+// WebSocket handshake get a 400, 403 or 426 response along with an
+// error saying what was wrong so the usual ErrHandler logging applies.
+type WebSocket struct {
+	Handle func(*http.Request, *WebSocketConn)
+	// Subprotocols are the supported subprotocols in order of
+	// preference. The first one the client also supports is used.
+	Subprotocols []string
+	// CheckOrigin reports whether the request's Origin header is
+	// acceptable. By default the Origin must be absent or have the
+	// same host as the request.
+	CheckOrigin func(*http.Request) bool
+	// ReadLimit is the largest message which will be read. It
+	// defaults to 1MiB.
+	ReadLimit int64
+}
+
+// ErrPresentHTTP validates the handshake and returns a 101 response
+// which will hand the connection to Handle once it is written.
+func (ws WebSocket) ErrPresentHTTP(r *http.Request) (Response, error) {
+	if r.Method != http.MethodGet {
+		return rejectWebSocket(http.StatusBadRequest, nil, "method must be GET")
+	}
+	if !headerHasToken(r.Header, "Connection", "upgrade") || !headerHasToken(r.Header, "Upgrade", "websocket") {
+		return rejectWebSocket(http.StatusUpgradeRequired, http.Header{"Upgrade": {"websocket"}}, "not a websocket upgrade request")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		return rejectWebSocket(http.StatusUpgradeRequired, http.Header{"Sec-Websocket-Version": {"13"}}, "unsupported websocket version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return rejectWebSocket(http.StatusBadRequest, nil, "invalid Sec-WebSocket-Key")
+	}
+	checkOrigin := ws.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = sameOrigin
+	}
+	if !checkOrigin(r) {
+		return rejectWebSocket(http.StatusForbidden, nil, "origin not allowed")
+	}
+	header := http.Header{
+		"Upgrade":              {"websocket"},
+		"Connection":           {"Upgrade"},
+		"Sec-Websocket-Accept": {websocketAccept(key)},
+	}
+	subprotocol := ws.subprotocol(r)
+	if subprotocol != "" {
+		header.Set("Sec-WebSocket-Protocol", subprotocol)
+	}
+	readLimit := ws.ReadLimit
+	if readLimit <= 0 {
+		readLimit = defaultReadLimit
+	}
+	return Response{
+		StatusCode: http.StatusSwitchingProtocols,
+		Header:     header,
+		Hijack: func(conn net.Conn, brw *bufio.ReadWriter) {
+			wsConn := &WebSocketConn{
+				Subprotocol: subprotocol,
+				conn:        conn,
+				brw:         brw,
+				readLimit:   readLimit,
+			}
+			defer wsConn.Close()
+			ws.Handle(r, wsConn)
+		},
+	}, nil
+}
+
+// subprotocol returns the preferred subprotocol the client supports.
+func (ws WebSocket) subprotocol(r *http.Request) string {
+	offered := map[string]bool{}
+	for _, value := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, protocol := range strings.Split(value, ",") {
+			offered[strings.TrimSpace(protocol)] = true
+		}
+	}
+	for _, protocol := range ws.Subprotocols {
+		if offered[protocol] {
+			return protocol
+		}
+	}
+	return ""
+}
+
+// rejectWebSocket returns the response and error for a bad handshake.
+func rejectWebSocket(statusCode int, header http.Header, reason string) (Response, error) {
+	return Response{
+		StatusCode: statusCode,
+		Header:     header,
+		Body:       []byte(reason),
+	}, fmt.Errorf("websocket handshake: %s", reason)
+}
+
+// websocketAccept returns the Sec-WebSocket-Accept value for a key.
+func websocketAccept(key string) string {
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// sameOrigin reports whether the request's Origin (if any) has the
+// same host as the request.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// headerHasToken reports whether a comma separated header contains a
+// token (compared case insensitively).
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, value := range h.Values(name) {
+		for _, t := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CloseError is returned from ReadMessage once the peer has closed the
+// connection.
+type CloseError struct {
+	Code int
+	Text string
+}
+
+func (c *CloseError) Error() string {
+	return fmt.Sprintf("websocket closed with code %d: %s", c.Code, c.Text)
+}
+
+// WebSocketConn is a message oriented WebSocket connection. One
+// goroutine may read from it while others write to it.
+type WebSocketConn struct {
+	// Subprotocol is the negotiated subprotocol, if any.
+	Subprotocol string
+
+	conn      net.Conn
+	brw       *bufio.ReadWriter
+	readLimit int64
+
+	writeMu   sync.Mutex
+	closeSent bool
+	closeOnce sync.Once
+}
+
+// NetConn returns the underlying connection, for example to set
+// deadlines on it.
+func (c *WebSocketConn) NetConn() net.Conn {
+	return c.conn
+}
+
+// ReadMessage reads the next message, which is either a TextMessage
+// or a BinaryMessage. Pings are answered along the way. Once the
+// peer closes the connection a *CloseError is returned, with a Code
+// of 1005 if the peer's close frame did not have one.
+func (c *WebSocketConn) ReadMessage() (int, []byte, error) {
+	messageType := 0
+	var data []byte
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+		switch opcode {
+		case pingFrame:
+			if err := c.writeFrame(pongFrame, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case pongFrame:
+			continue
+		case closeFrame:
+			if len(payload) == 1 {
+				return 0, nil, c.fail(closeProtocolError, "close frame payload is too short")
+			}
+			if len(payload) == 0 {
+				// 1005 means no code was sent so it must not be
+				// sent back either. An empty close frame is.
+				c.writeFrame(closeFrame, nil)
+				return 0, nil, &CloseError{Code: closeNoStatus}
+			}
+			code := int(binary.BigEndian.Uint16(payload))
+			if !validCloseCode(code) {
+				return 0, nil, c.fail(closeProtocolError, fmt.Sprintf("invalid close code %d", code))
+			}
+			if !utf8.Valid(payload[2:]) {
+				return 0, nil, c.fail(closeInvalidPayload, "close reason is not valid UTF-8")
+			}
+			c.sendClose(code, "")
+			return 0, nil, &CloseError{Code: code, Text: string(payload[2:])}
+		case TextMessage, BinaryMessage:
+			if messageType != 0 {
+				return 0, nil, c.fail(closeProtocolError, "expected a continuation frame")
+			}
+			messageType = opcode
+			data = payload
+		case continuationFrame:
+			if messageType == 0 {
+				return 0, nil, c.fail(closeProtocolError, "unexpected continuation frame")
+			}
+			if int64(len(data)+len(payload)) > c.readLimit {
+				return 0, nil, c.fail(closeMessageTooBig, "message too big")
+			}
+			data = append(data, payload...)
+		default:
+			return 0, nil, c.fail(closeProtocolError, fmt.Sprintf("unknown opcode %d", opcode))
+		}
+		if !fin {
+			continue
+		}
+		if messageType == TextMessage && !utf8.Valid(data) {
+			return 0, nil, c.fail(closeInvalidPayload, "text message is not valid UTF-8")
+		}
+		return messageType, data, nil
+	}
+}
+
+// WriteMessage writes a TextMessage or BinaryMessage.
+func (c *WebSocketConn) WriteMessage(messageType int, data []byte) error {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return fmt.Errorf("websocket: invalid message type %d", messageType)
+	}
+	return c.writeFrame(byte(messageType), data)
+}
+
+// Close sends a close frame (unless one was already sent) and closes
+// the connection.
+func (c *WebSocketConn) Close() error {
+	c.sendClose(closeNormal, "")
+	var err error
+	c.closeOnce.Do(func() {
+		err = c.conn.Close()
+	})
+	return err
+}
+
+// fail sends a close frame because the peer broke the protocol and
+// returns the error describing how.
+func (c *WebSocketConn) fail(code int, reason string) error {
+	c.sendClose(code, reason)
+	return fmt.Errorf("websocket: %s", reason)
+}
+
+// validCloseCode reports whether a peer may send a close code. Codes
+// such as 1005 and 1006 only exist to be reported locally and the
+// rest of the 1000-2999 range is reserved for future versions of the
+// protocol.
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 3000 && code <= 4999:
+		return true
+	case code >= 1000 && code <= 1003, code >= 1007 && code <= 1014:
+		return true
+	}
+	return false
+}
+
+// sendClose sends a close frame unless one has already been sent.
+func (c *WebSocketConn) sendClose(code int, reason string) {
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	c.writeFrame(closeFrame, append(payload, reason...))
+}
+
+// readFrame reads a single frame and unmasks its payload.
+func (c *WebSocketConn) readFrame() (bool, int, []byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(c.brw, head[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin := head[0]&0x80 != 0
+	opcode := int(head[0] & 0x0f)
+	if head[0]&0x70 != 0 {
+		return false, 0, nil, c.fail(closeProtocolError, "reserved bits are set")
+	}
+	if head[1]&0x80 == 0 {
+		return false, 0, nil, c.fail(closeProtocolError, "client frames must be masked")
+	}
+	length := uint64(head[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.brw, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.brw, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if opcode >= closeFrame && (length > 125 || !fin) {
+		return false, 0, nil, c.fail(closeProtocolError, "invalid control frame")
+	}
+	if length > uint64(c.readLimit) {
+		return false, 0, nil, c.fail(closeMessageTooBig, "message too big")
+	}
+	var mask [4]byte
+	if _, err := io.ReadFull(c.brw, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.brw, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, opcode, payload, nil
+}
+
+// writeFrame writes a single unmasked frame.
+func (c *WebSocketConn) writeFrame(opcode byte, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closeSent {
+		return errors.New("websocket: close frame already sent")
+	}
+	if opcode == closeFrame {
+		c.closeSent = true
+	}
+	header := []byte{0x80 | opcode}
+	switch length := len(payload); {
+	case length <= 125:
+		header = append(header, byte(length))
+	case length <= 0xffff:
+		header = append(header, 126)
+		header = binary.BigEndian.AppendUint16(header, uint16(length))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(length))
+	}
+	if _, err := c.brw.Write(header); err != nil {
+		return err
+	}
+	if _, err := c.brw.Write(payload); err != nil {
+		return err
+	}
+	return c.brw.Flush()
+}
@@ -0,0 +1,329 @@
+package httphandler_test
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lag13/httphandler"
+)
+
+// wsClient is just enough of a WebSocket client to test the server.
+type wsClient struct {
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+// dialWebSocket performs the handshake and returns the response.
+func dialWebSocket(t *testing.T, serverURL string, header http.Header) (*wsClient, *http.Response) {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(serverURL, "http://"))
+	if err != nil {
+		t.Fatalf("dialing: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	req, _ := http.NewRequest(http.MethodGet, serverURL+"/ws", nil)
+	req.Header = header
+	if err := req.Write(conn); err != nil {
+		t.Fatalf("writing handshake: %v", err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		t.Fatalf("reading handshake response: %v", err)
+	}
+	return &wsClient{conn: conn, br: br}, resp
+}
+
+// writeFrame writes a masked frame.
+func (c *wsClient) writeFrame(t *testing.T, fin bool, opcode byte, payload []byte) {
+	t.Helper()
+	first := opcode
+	if fin {
+		first |= 0x80
+	}
+	frame := []byte{first, 0x80 | byte(len(payload))}
+	mask := []byte{1, 2, 3, 4}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	if _, err := c.conn.Write(frame); err != nil {
+		t.Fatalf("writing frame: %v", err)
+	}
+}
+
+// readFrame reads an unmasked frame.
+func (c *wsClient) readFrame(t *testing.T) (byte, []byte) {
+	t.Helper()
+	var head [2]byte
+	if _, err := io.ReadFull(c.br, head[:]); err != nil {
+		t.Fatalf("reading frame: %v", err)
+	}
+	length := int(head[1] & 0x7f)
+	if length == 126 {
+		var ext [2]byte
+		io.ReadFull(c.br, ext[:])
+		length = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		t.Fatalf("reading payload: %v", err)
+	}
+	return head[0] & 0x0f, payload
+}
+
+// handshakeHeader returns the header of a valid handshake.
+func handshakeHeader() http.Header {
+	return http.Header{
+		"Connection":             {"keep-alive, Upgrade"},
+		"Upgrade":                {"websocket"},
+		"Sec-Websocket-Version":  {"13"},
+		"Sec-Websocket-Key":      {"dGhlIHNhbXBsZSBub25jZQ=="},
+		"Sec-Websocket-Protocol": {"v1.chat, v2.chat"},
+	}
+}
+
+// TestWebSocketEcho tests the handshake and exchanging messages.
+func TestWebSocketEcho(t *testing.T) {
+	handlerErrs := make(chan error, 1)
+	server := httptest.NewServer(httphandler.Writer{
+		Presenter: httphandler.Dispatcher{
+			MethodToPresenter: map[string]httphandler.Presenter{
+				http.MethodGet: httphandler.ErrHandler{
+					ErrPresenter: httphandler.WebSocket{
+						Subprotocols: []string{"v2.chat", "v1.chat"},
+						Handle: func(r *http.Request, conn *httphandler.WebSocketConn) {
+							for {
+								messageType, data, err := conn.ReadMessage()
+								if err != nil {
+									handlerErrs <- err
+									return
+								}
+								conn.WriteMessage(messageType, append([]byte(conn.Subprotocol+": "), data...))
+							}
+						},
+					},
+					HandleErr: func(r *http.Request, err error) {
+						t.Errorf("got unexpected error: %v", err)
+					},
+				},
+			},
+		},
+	})
+	defer server.Close()
+
+	client, resp := dialWebSocket(t, server.URL, handshakeHeader())
+
+	if got, want := resp.StatusCode, http.StatusSwitchingProtocols; got != want {
+		t.Fatalf("got status code %v, wanted %v", got, want)
+	}
+	if got, want := resp.Header.Get("Sec-WebSocket-Accept"), "s3pPLMBiTxaQ9kYGzzhZRbK+xOo="; got != want {
+		t.Errorf("got accept %s, wanted %s", got, want)
+	}
+	if got, want := resp.Header.Get("Sec-WebSocket-Protocol"), "v2.chat"; got != want {
+		t.Errorf("got subprotocol %s, wanted %s", got, want)
+	}
+	client.writeFrame(t, true, 9, []byte("ping!"))
+	if opcode, payload := client.readFrame(t); opcode != 10 || string(payload) != "ping!" {
+		t.Errorf("got frame %d %q, wanted a pong", opcode, payload)
+	}
+	client.writeFrame(t, false, 1, []byte("hello "))
+	client.writeFrame(t, true, 0, []byte("world"))
+	if opcode, payload := client.readFrame(t); opcode != 1 || string(payload) != "v2.chat: hello world" {
+		t.Errorf("got frame %d %q, wanted the echoed message", opcode, payload)
+	}
+	client.writeFrame(t, true, 8, []byte{0x03, 0xe8, 'b', 'y', 'e'})
+	if opcode, payload := client.readFrame(t); opcode != 8 || binary.BigEndian.Uint16(payload) != 1000 {
+		t.Errorf("got frame %d %q, wanted a close frame", opcode, payload)
+	}
+	var closeErr *httphandler.CloseError
+	if err := <-handlerErrs; !errors.As(err, &closeErr) || closeErr.Code != 1000 || closeErr.Text != "bye" {
+		t.Errorf("got error %v, wanted a close error", err)
+	}
+}
+
+// TestWebSocketClose tests that close frames are answered with the
+// right close frame.
+func TestWebSocketClose(t *testing.T) {
+	tests := []struct {
+		name        string
+		payload     []byte
+		wantPayload []byte
+		wantErrMsg  string
+	}{
+		{
+			name:        "with a code",
+			payload:     []byte{0x03, 0xe9, 'g', 'o', 'n', 'e'},
+			wantPayload: []byte{0x03, 0xe9},
+			wantErrMsg:  "websocket closed with code 1001: gone",
+		},
+		{
+			name:        "without a code",
+			payload:     []byte{},
+			wantPayload: []byte{},
+			wantErrMsg:  "websocket closed with code 1005: ",
+		},
+		{
+			name:        "with an application code",
+			payload:     []byte{0x0f, 0xa0},
+			wantPayload: []byte{0x0f, 0xa0},
+			wantErrMsg:  "websocket closed with code 4000: ",
+		},
+		{
+			name:        "with code 1005",
+			payload:     []byte{0x03, 0xed},
+			wantPayload: append([]byte{0x03, 0xea}, "invalid close code 1005"...),
+			wantErrMsg:  "websocket: invalid close code 1005",
+		},
+		{
+			name:        "with code 1006",
+			payload:     []byte{0x03, 0xee},
+			wantPayload: append([]byte{0x03, 0xea}, "invalid close code 1006"...),
+			wantErrMsg:  "websocket: invalid close code 1006",
+		},
+		{
+			name:        "with code 0",
+			payload:     []byte{0x00, 0x00},
+			wantPayload: append([]byte{0x03, 0xea}, "invalid close code 0"...),
+			wantErrMsg:  "websocket: invalid close code 0",
+		},
+		{
+			name:        "with a reason which is not UTF-8",
+			payload:     []byte{0x03, 0xe8, 0xff},
+			wantPayload: append([]byte{0x03, 0xef}, "close reason is not valid UTF-8"...),
+			wantErrMsg:  "websocket: close reason is not valid UTF-8",
+		},
+		{
+			name:        "a one byte payload",
+			payload:     []byte{0x03},
+			wantPayload: append([]byte{0x03, 0xea}, "close frame payload is too short"...),
+			wantErrMsg:  "websocket: close frame payload is too short",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			handlerErrs := make(chan error, 1)
+			server := httptest.NewServer(httphandler.Writer{
+				Presenter: httphandler.ErrHandler{
+					ErrPresenter: httphandler.WebSocket{
+						Handle: func(r *http.Request, conn *httphandler.WebSocketConn) {
+							_, _, err := conn.ReadMessage()
+							handlerErrs <- err
+						},
+					},
+				},
+			})
+			defer server.Close()
+			client, _ := dialWebSocket(t, server.URL, handshakeHeader())
+
+			client.writeFrame(t, true, 8, test.payload)
+
+			if opcode, payload := client.readFrame(t); opcode != 8 || string(payload) != string(test.wantPayload) {
+				t.Errorf("got frame %d %v, wanted close frame %v", opcode, payload, test.wantPayload)
+			}
+			if got, want := fmt.Sprintf("%v", <-handlerErrs), test.wantErrMsg; got != want {
+				t.Errorf("got error msg: %s, wanted error msg: %s", got, want)
+			}
+		})
+	}
+}
+
+// TestWebSocketRejected tests that bad handshakes get normal responses
+// and errors.
+func TestWebSocketRejected(t *testing.T) {
+	tests := []struct {
+		name           string
+		modify         func(http.Header)
+		wantStatusCode int
+		wantErrMsg     string
+	}{
+		{
+			name:           "not an upgrade",
+			modify:         func(h http.Header) { h.Del("Upgrade") },
+			wantStatusCode: http.StatusUpgradeRequired,
+			wantErrMsg:     "websocket handshake: not a websocket upgrade request",
+		},
+		{
+			name:           "wrong version",
+			modify:         func(h http.Header) { h.Set("Sec-WebSocket-Version", "8") },
+			wantStatusCode: http.StatusUpgradeRequired,
+			wantErrMsg:     "websocket handshake: unsupported websocket version",
+		},
+		{
+			name:           "bad key",
+			modify:         func(h http.Header) { h.Set("Sec-WebSocket-Key", "short") },
+			wantStatusCode: http.StatusBadRequest,
+			wantErrMsg:     "websocket handshake: invalid Sec-WebSocket-Key",
+		},
+		{
+			name:           "cross origin",
+			modify:         func(h http.Header) { h.Set("Origin", "https://evil.example") },
+			wantStatusCode: http.StatusForbidden,
+			wantErrMsg:     "websocket handshake: origin not allowed",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fnErrHandler := fnToHandleErr{}
+			sut := httphandler.ErrHandler{
+				ErrPresenter: httphandler.WebSocket{
+					Handle: func(*http.Request, *httphandler.WebSocketConn) {
+						t.Errorf("Handle should not have been called")
+					},
+				},
+				HandleErr: fnErrHandler.handleError,
+			}
+			req := httptest.NewRequest(http.MethodGet, "http://example.com/ws", nil)
+			req.Header = handshakeHeader()
+			req.Header.Set("Origin", "https://example.com")
+			test.modify(req.Header)
+
+			gotResp := sut.PresentHTTP(req)
+
+			if got, want := gotResp.StatusCode, test.wantStatusCode; got != want {
+				t.Errorf("got status code %v, wanted %v", got, want)
+			}
+			if gotResp.Hijack != nil {
+				t.Errorf("a rejected handshake should not hijack the connection")
+			}
+			if fnErrHandler.gotErr == nil || fnErrHandler.gotErr.Error() != test.wantErrMsg {
+				t.Errorf("got error %v, wanted error msg: %s", fnErrHandler.gotErr, test.wantErrMsg)
+			}
+		})
+	}
+}
+
+// TestWriterHijackUnsupported tests that Writer reports an error when
+// the connection cannot be hijacked.
+func TestWriterHijackUnsupported(t *testing.T) {
+	fnErrHandler := fnToHandleErr{}
+	sut := httphandler.Writer{
+		Presenter: httphandler.PresenterFunc(func(*http.Request) httphandler.Response {
+			return httphandler.Response{
+				StatusCode: http.StatusSwitchingProtocols,
+				Hijack: func(net.Conn, *bufio.ReadWriter) {
+					t.Errorf("Hijack should not have been called")
+				},
+			}
+		}),
+		HandleErr: fnErrHandler.handleError,
+	}
+	w := httptest.NewRecorder()
+
+	sut.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ws", nil))
+
+	if got, want := w.Code, http.StatusInternalServerError; got != want {
+		t.Errorf("got status code %v, wanted %v", got, want)
+	}
+	if !fnErrHandler.wasInvoked {
+		t.Errorf("the hijack error was not reported")
+	}
+}